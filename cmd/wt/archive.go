@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niref/wt/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Inspect archives of removed worktrees",
+}
+
+var archiveListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List archives of removed worktrees",
+	Long: `List archives saved when worktrees were removed, newest first.
+
+Restore one with 'wt restore <name> [--id <id>]'. Archives are plain refs under
+refs/wt/archive/; delete one with 'git update-ref -d <ref>'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoRoot, err := worktree.FindRepoRoot(cwd)
+		if err != nil {
+			return fmt.Errorf("not in a git repository")
+		}
+
+		archives, err := worktree.NewManager(repoRoot).Archives()
+		if err != nil {
+			return err
+		}
+
+		if len(archives) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No archives found")
+			return nil
+		}
+
+		return writeArchiveTable(cmd.OutOrStdout(), archives, time.Now())
+	},
+}
+
+func init() {
+	archiveCmd.AddCommand(archiveListCmd)
+	rootCmd.AddCommand(archiveCmd)
+}
+
+// writeArchiveTable renders archives as an aligned table.
+func writeArchiveTable(w io.Writer, archives []worktree.Archive, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tBRANCH\tCHANGES\tARCHIVED\tID")
+	for _, a := range archives {
+		branch := a.Branch
+		if branch == "" || branch == "HEAD" {
+			branch = "-"
+		}
+		changes := "clean"
+		if a.Dirty {
+			changes = "uncommitted"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Name, branch, changes, formatAge(now.Sub(a.Created)), a.ID)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/niref/wt/internal/worktree"
+)
+
+// removalIssues lists what would be lost by removing a worktree.
+func removalIssues(hasUncommitted, hasUnpushed bool) []string {
+	var issues []string
+	if hasUncommitted {
+		issues = append(issues, "uncommitted changes")
+	}
+	if hasUnpushed {
+		issues = append(issues, "unpushed commits")
+	}
+	return issues
+}
+
+// hasUnpushedCommits reports whether removing a worktree's branch would lose commits:
+// commits ahead of its upstream, or, without an upstream, commits on no remote at all.
+func hasUnpushedCommits(mgr *worktree.Manager, wt worktree.WorktreeInfo, status worktree.WorktreeStatus) bool {
+	if wt.Branch == "" {
+		return false
+	}
+	if status.Upstream != "" {
+		return status.Ahead > 0
+	}
+	return mgr.UnpublishedCount(wt.Branch) > 0
+}
+
+// confirmRemoval asks whether to remove a worktree despite issues.
+// Returns true only for an explicit "y" or "yes" answer.
+func confirmRemoval(out io.Writer, in *bufio.Reader, name string, issues []string) (bool, error) {
+	fmt.Fprintf(out, "Remove %s? It has %s [y/n]: ", name, strings.Join(issues, " and "))
+
+	input, err := in.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	input = strings.TrimSpace(strings.ToLower(input))
+	return input == "y" || input == "yes", nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niref/wt/internal/sandbox"
+	"github.com/niref/wt/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fanoutCount int
+	fanoutBase  string
+	fanoutName  string
+	fanoutJobs  int
+	fanoutJSON  bool
+)
+
+// fanoutRun is the outcome of one run of a fanout.
+type fanoutRun struct {
+	Name     string        `json:"name"`
+	Branch   string        `json:"branch"`
+	Path     string        `json:"path"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"` // The run could not be started or logged
+	DiffStat string        `json:"diffstat"`
+	Duration time.Duration `json:"duration_ns"`
+	LogDir   string        `json:"log_dir,omitempty"`
+}
+
+// Failed reports whether the run did not finish successfully.
+func (r fanoutRun) Failed() bool {
+	return r.Error != "" || r.ExitCode != 0
+}
+
+// fanoutOutput is the --json document for `wt fanout`.
+type fanoutOutput struct {
+	Version int         `json:"version"`
+	Base    string      `json:"base"`
+	Runs    []fanoutRun `json:"runs"`
+}
+
+var fanoutCmd = &cobra.Command{
+	Use:   "fanout --count <n> (--prompt <task> | --prompt-file <file>)",
+	Short: "Run one task in several sandboxed worktrees in parallel",
+	Long: `Create --count worktrees named <name>-1 ... <name>-<n> from --base and run
+Claude headless on the same task in a sandbox in each, up to --jobs at a time.
+
+Each run is logged like 'wt sandbox --prompt'. When all runs have finished, wt
+prints each run's exit status, changes relative to the base and duration. The
+worktrees are kept so the results can be compared, picked from and removed with
+'wt rm'; if one of them cannot be set up, those already created are removed
+before anything runs. Takes the same flags as 'wt sandbox'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prompt, err := readPrompt(cmd)
+		if err != nil {
+			return err
+		}
+		if prompt == "" {
+			return fmt.Errorf("a task is required: use --prompt or --prompt-file")
+		}
+		if sandboxNoClaude {
+			return fmt.Errorf("--no-claude cannot be used with wt fanout")
+		}
+		if fanoutCount < 1 {
+			return fmt.Errorf("--count must be at least 1")
+		}
+
+		repoRoot, err := currentRepoRoot()
+		if err != nil {
+			return err
+		}
+		mgr := worktree.NewManager(repoRoot)
+
+		base := fanoutBase
+		if base == "" {
+			base = "HEAD"
+		}
+		baseCommit, err := mgr.ResolveCommit(base)
+		if err != nil {
+			return err
+		}
+
+		prefix := fanoutName
+		if prefix == "" {
+			prefix = "fanout-" + time.Now().Format("0102-1504")
+		}
+		names := make([]string, fanoutCount)
+		for i := range names {
+			names[i] = fmt.Sprintf("%s-%d", prefix, i+1)
+			if mgr.Exists(names[i]) {
+				return fmt.Errorf("worktree %q already exists (choose another --name)", names[i])
+			}
+		}
+
+		setups := make([]*sandboxSetup, 0, len(names))
+		defer func() {
+			for _, s := range setups {
+				s.close()
+			}
+		}()
+		for i, name := range names {
+			setup, err := setupFanout(cmd, mgr, name, baseCommit)
+			if err != nil {
+				for _, s := range setups {
+					s.close()
+				}
+				setups = nil
+				removeFanoutWorktrees(cmd, mgr, names[:i+1])
+				return err
+			}
+			setup.opts.Prompt = prompt
+			setups = append(setups, setup)
+		}
+
+		stderr := cmd.ErrOrStderr()
+		var mu sync.Mutex
+		progress := func(format string, a ...any) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(stderr, format, a...)
+		}
+
+		runs := make([]fanoutRun, len(setups))
+		slots := make(chan struct{}, max(fanoutJobs, 1))
+		var wg sync.WaitGroup
+		for i, setup := range setups {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				progress("%s: started\n", setup.name)
+				runs[i] = runFanout(mgr, setup, baseCommit, progress)
+				if runs[i].Error != "" {
+					progress("%s: %s\n", setup.name, runs[i].Error)
+				} else {
+					progress("%s: finished with exit status %d after %s\n", setup.name, runs[i].ExitCode, formatDuration(runs[i].Duration))
+				}
+			}()
+		}
+		wg.Wait()
+
+		if fanoutJSON {
+			if err := writeJSON(cmd.OutOrStdout(), fanoutOutput{Version: jsonSchemaVersion, Base: baseCommit, Runs: runs}); err != nil {
+				return err
+			}
+		} else if err := writeFanoutTable(cmd.OutOrStdout(), runs); err != nil {
+			return err
+		}
+
+		var failed int
+		for _, r := range runs {
+			if r.Failed() {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return &exitCodeError{code: 1, msg: fmt.Sprintf("%d of %d runs failed", failed, len(runs))}
+		}
+		return nil
+	},
+}
+
+// setupFanout creates one worktree of a fanout from baseCommit and sets up its sandbox.
+func setupFanout(cmd *cobra.Command, mgr *worktree.Manager, name, baseCommit string) (*sandboxSetup, error) {
+	if err := mgr.CreateNew(name, baseCommit); err != nil {
+		return nil, err
+	}
+	if err := mgr.CopyWorktreeInclude(name); err != nil {
+		return nil, fmt.Errorf("copying .worktreeinclude files: %w", err)
+	}
+	return setupSandbox(cmd, []string{name})
+}
+
+// removeFanoutWorktrees removes the worktrees a fanout created before it failed
+// to set up the rest, and lists those it could not remove.
+func removeFanoutWorktrees(cmd *cobra.Command, mgr *worktree.Manager, names []string) {
+	for _, name := range names {
+		if !mgr.Exists(name) {
+			continue
+		}
+		if err := mgr.Remove(name, true); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: could not remove worktree %s: %v\n", name, err)
+		}
+	}
+}
+
+// runFanout runs one headless sandbox of a fanout. Claude's rendered output goes
+// to output.log in the run's log directory instead of the terminal; branch
+// updates and warnings are reported through progress.
+func runFanout(mgr *worktree.Manager, setup *sandboxSetup, baseCommit string, progress func(string, ...any)) fanoutRun {
+	run := fanoutRun{
+		Name:   setup.name,
+		Branch: worktree.BranchName(setup.name),
+		Path:   setup.opts.WorktreePath,
+	}
+
+	logDir, err := sandbox.LogDir(setup.repoRoot, setup.name)
+	if err == nil {
+		run.LogDir, err = sandbox.NewRunDir(logDir, time.Now())
+	}
+	var output *os.File
+	if err == nil {
+		output, err = os.Create(filepath.Join(run.LogDir, "output.log"))
+	}
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	defer output.Close()
+
+	audit, err := beginAudit(setup, sandbox.AuditHeadless)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	status, err := sandbox.RunHeadless(setup.opts, run.LogDir, output)
+	if isolation := setup.opts.GitIsolation; isolation != nil {
+		updated, err := isolation.Sync()
+		for _, u := range updated {
+			progress("%s: updated branch %s\n", setup.name, u)
+		}
+		if err != nil {
+			progress("%s: warning: %v\n", setup.name, err)
+		}
+	}
+	if audit != nil {
+		audit.LogDir = run.LogDir
+		exitCode := status.ExitCode
+		if err != nil {
+			exitCode = -1
+		}
+		if err := finishAudit(audit, exitCode); err != nil {
+			progress("%s: warning: %v\n", setup.name, err)
+		}
+	}
+	run.ExitCode = status.ExitCode
+	run.Duration = status.Duration()
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	run.DiffStat, err = mgr.DiffStat(run.Path, baseCommit)
+	if err != nil {
+		run.DiffStat = "?"
+	}
+	return run
+}
+
+// writeFanoutTable renders fanout runs as an aligned table.
+func writeFanoutTable(w io.Writer, runs []fanoutRun) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tEXIT\tDURATION\tCHANGES\tLOGS")
+	for _, r := range runs {
+		exit := fmt.Sprint(r.ExitCode)
+		if r.Error != "" {
+			exit = "error"
+		}
+		changes := r.DiffStat
+		if changes == "" {
+			changes = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, exit, formatDuration(r.Duration), changes, r.LogDir)
+	}
+	return tw.Flush()
+}
+
+// formatDuration rounds d to whole seconds for display.
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func init() {
+	addSandboxRunFlags(fanoutCmd.Flags())
+	fanoutCmd.Flags().IntVarP(&fanoutCount, "count", "n", 2, "Number of worktrees to run the task in")
+	fanoutCmd.Flags().StringVar(&fanoutBase, "base", "", "Ref to start the branches from (default: HEAD)")
+	fanoutCmd.Flags().StringVar(&fanoutName, "name", "", "Worktree name prefix (default: fanout-<date>-<time>)")
+	fanoutCmd.Flags().IntVarP(&fanoutJobs, "jobs", "j", 4, "Number of sandboxes to run at a time")
+	fanoutCmd.Flags().BoolVar(&fanoutJSON, "json", false, "Output the summary as JSON")
+	fanoutCmd.Flags().StringVar(&sandboxPrompt, "prompt", "", "Task to run in every worktree")
+	fanoutCmd.Flags().StringVar(&sandboxPromptF, "prompt-file", "", "Like --prompt, reading the task from a file ('-' for stdin)")
+	fanoutCmd.Flags().StringVar(&sandboxRuntime, "runtime", "auto", "Container runtime: podman, docker or auto")
+	fanoutCmd.MarkFlagsMutuallyExclusive("prompt", "prompt-file")
+	rootCmd.AddCommand(fanoutCmd)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/niref/wt/internal/worktree"
+)
+
+func TestWriteFanoutTable(t *testing.T) {
+	runs := []fanoutRun{
+		{Name: "try-1", ExitCode: 0, DiffStat: "2 files changed, 10 insertions(+)", Duration: 95*time.Second + 300*time.Millisecond, LogDir: "/logs/try-1/20250301-143000"},
+		{Name: "try-2", ExitCode: 1, Duration: 40 * time.Second, LogDir: "/logs/try-2/20250301-143000"},
+		{Name: "try-3", Error: "podman run: exit status 125"},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeFanoutTable(buf, runs); err != nil {
+		t.Fatal(err)
+	}
+	// Runs that could not start show "error"; runs without changes show "-"
+	want := "NAME   EXIT   DURATION  CHANGES                            LOGS\n" +
+		"try-1  0      1m35s     2 files changed, 10 insertions(+)  /logs/try-1/20250301-143000\n" +
+		"try-2  1      40s       -                                  /logs/try-2/20250301-143000\n" +
+		"try-3  error  0s        -                                  \n"
+	if buf.String() != want {
+		t.Errorf("writeFanoutTable() =\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	if runs[0].Failed() || !runs[1].Failed() || !runs[2].Failed() {
+		t.Error("Failed() should be set for non-zero exit codes and errors")
+	}
+}
+
+func TestFanout_RequiresPrompt(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+	}()
+
+	rootCmd.SetArgs([]string{"fanout", "--count", "2"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--prompt") {
+		t.Errorf("fanout without a task: err = %v, want a --prompt error", err)
+	}
+}
+
+func TestFanout_RemovesWorktreesWhenSetupFails(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	mgr := worktree.NewManager(repoDir)
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		fanoutCount = 2
+		fanoutName = ""
+		sandboxPrompt = ""
+		sandboxRuntime = "auto"
+	}()
+
+	// The sandbox of the first worktree fails to set up after it was created
+	rootCmd.SetArgs([]string{"fanout", "--count", "2", "--name", "try", "--prompt", "fix it", "--runtime", "none"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Fatalf("fanout with an unknown runtime succeeded:\n%s", buf.String())
+	}
+	for _, name := range []string{"try-1", "try-2"} {
+		if mgr.Exists(name) {
+			t.Errorf("worktree %s was left behind", name)
+		}
+		if mgr.BranchExists(worktree.BranchName(name)) {
+			t.Errorf("branch of %s was left behind", name)
+		}
+	}
+}
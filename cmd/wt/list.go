@@ -2,15 +2,33 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/niref/wt/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
+var (
+	listJSON   bool
+	listFormat string
+	listJobs   int
+)
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List worktrees for current repo",
+	Long: `List worktrees under .claude/worktrees/ with their status.
+
+Columns show uncommitted changes, commits ahead (↑) and behind (↓) the
+upstream branch and the main branch, the age of the last commit, and the ports
+reserved for the worktree's dev servers ($WT_PORT_BASE in 'wt sandbox').
+
+Use --json for a versioned machine-readable document, or --format to render
+each worktree with a Go template, e.g. --format '{{.Name}} {{.Branch}}'.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -28,19 +46,148 @@ var listCmd = &cobra.Command{
 			return err
 		}
 
+		if worktrees == nil {
+			worktrees = []worktree.WorktreeInfo{}
+		}
+
+		mainBranch, _ := worktree.GetMainBranch(repoRoot)
+		mgr.CollectStatus(worktrees, mainBranch, listJobs)
+
+		switch {
+		case listJSON:
+			return writeJSON(cmd.OutOrStdout(), listOutput{Version: jsonSchemaVersion, Worktrees: worktrees})
+		case listFormat != "":
+			return writeTemplate(cmd.OutOrStdout(), listFormat, worktrees)
+		}
+
 		if len(worktrees) == 0 {
 			fmt.Fprintln(cmd.OutOrStdout(), "No worktrees found")
 			return nil
 		}
 
-		for _, wt := range worktrees {
-			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\n", wt.Name, wt.Branch, wt.Path)
-		}
-
-		return nil
+		return writeListTable(cmd.OutOrStdout(), worktrees, mainBranch, time.Now())
 	},
 }
 
 func init() {
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "Output as JSON")
+	listCmd.Flags().StringVar(&listFormat, "format", "", "Format each worktree with a Go template")
+	listCmd.MarkFlagsMutuallyExclusive("json", "format")
+	listCmd.Flags().IntVarP(&listJobs, "jobs", "j", worktree.DefaultJobs(), "Number of worktrees to inspect in parallel")
 	rootCmd.AddCommand(listCmd)
 }
+
+// writeListTable renders worktrees as an aligned table.
+func writeListTable(w io.Writer, worktrees []worktree.WorktreeInfo, mainBranch string, now time.Time) error {
+	baseHeader := "BASE"
+	if mainBranch != "" {
+		baseHeader = "VS " + strings.ToUpper(mainBranch)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tBRANCH\tCHANGES\tUPSTREAM\t%s\tLAST COMMIT\tPORTS\tPATH\n", baseHeader)
+	for _, wt := range worktrees {
+		branch := wt.Branch
+		if branch == "" {
+			branch = "-"
+		}
+		changes, upstream, base, age := "-", "-", "-", "-"
+		if s := wt.Status; s != nil {
+			changes = formatChanges(s.Uncommitted)
+			if s.Upstream != "" {
+				upstream = formatAheadBehind(s.Ahead, s.Behind)
+			}
+			if s.BaseBranch != "" && s.BaseBranch != wt.Branch {
+				base = formatAheadBehind(s.BaseAhead, s.BaseBehind)
+			}
+			if !s.LastCommit.IsZero() {
+				age = formatAge(now.Sub(s.LastCommit))
+			}
+		}
+		path := wt.Path
+		if flags := worktreeFlags(wt); len(flags) > 0 {
+			path += " (" + strings.Join(flags, ", ") + ")"
+		}
+		ports := "-"
+		if wt.Ports.Count > 0 {
+			ports = wt.Ports.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", wt.Name, branch, changes, upstream, base, age, ports, path)
+	}
+	return tw.Flush()
+}
+
+// formatChanges describes the number of uncommitted paths.
+func formatChanges(n int) string {
+	if n == 0 {
+		return "clean"
+	}
+	return fmt.Sprintf("%d changed", n)
+}
+
+// formatAheadBehind renders ahead/behind counts, e.g. "↑2 ↓1" or "=" when in sync.
+func formatAheadBehind(ahead, behind int) string {
+	if ahead == 0 && behind == 0 {
+		return "="
+	}
+	var parts []string
+	if ahead > 0 {
+		parts = append(parts, fmt.Sprintf("↑%d", ahead))
+	}
+	if behind > 0 {
+		parts = append(parts, fmt.Sprintf("↓%d", behind))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatAge renders a duration as a short relative age, e.g. "3h ago".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 14*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	default:
+		return fmt.Sprintf("%dw ago", int(d.Hours()/(24*7)))
+	}
+}
+
+// worktreeFlags describes the git state of a worktree that needs attention.
+func worktreeFlags(wt worktree.WorktreeInfo) []string {
+	var flags []string
+	if wt.Unregistered {
+		flags = append(flags, "not a git worktree")
+	}
+	if wt.Missing {
+		flags = append(flags, "directory missing")
+	}
+	if wt.Detached {
+		head := wt.Head
+		if len(head) > 7 {
+			head = head[:7]
+		}
+		flags = append(flags, "detached at "+head)
+	}
+	if wt.Bare {
+		flags = append(flags, "bare")
+	}
+	if wt.Locked {
+		if wt.LockReason != "" {
+			flags = append(flags, "locked: "+wt.LockReason)
+		} else {
+			flags = append(flags, "locked")
+		}
+	}
+	if wt.Prunable && !wt.Missing {
+		if wt.PrunableReason != "" {
+			flags = append(flags, "prunable: "+wt.PrunableReason)
+		} else {
+			flags = append(flags, "prunable")
+		}
+	}
+	return flags
+}
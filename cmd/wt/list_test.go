@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/niref/wt/internal/worktree"
+)
+
+func TestWorktreeFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		wt   worktree.WorktreeInfo
+		want string
+	}{
+		{
+			name: "healthy worktree",
+			wt:   worktree.WorktreeInfo{Name: "feature", Branch: "feature"},
+			want: "",
+		},
+		{
+			name: "unregistered directory",
+			wt:   worktree.WorktreeInfo{Name: "stray", Unregistered: true},
+			want: "not a git worktree",
+		},
+		{
+			name: "missing directory hides redundant prunable reason",
+			wt:   worktree.WorktreeInfo{Name: "gone", Missing: true, Prunable: true, PrunableReason: "gitdir file points to non-existent location"},
+			want: "directory missing",
+		},
+		{
+			name: "detached and locked",
+			wt:   worktree.WorktreeInfo{Name: "pinned", Head: "0123456789abcdef", Detached: true, Locked: true, LockReason: "on usb"},
+			want: "detached at 0123456, locked: on usb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(worktreeFlags(tt.wt), ", ")
+			if got != tt.want {
+				t.Errorf("worktreeFlags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAheadBehind(t *testing.T) {
+	tests := []struct {
+		ahead, behind int
+		want          string
+	}{
+		{0, 0, "="},
+		{2, 0, "↑2"},
+		{0, 3, "↓3"},
+		{1, 4, "↑1 ↓4"},
+	}
+	for _, tt := range tests {
+		if got := formatAheadBehind(tt.ahead, tt.behind); got != tt.want {
+			t.Errorf("formatAheadBehind(%d, %d) = %q, want %q", tt.ahead, tt.behind, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "just now"},
+		{5 * time.Minute, "5m ago"},
+		{3 * time.Hour, "3h ago"},
+		{2 * 24 * time.Hour, "2d ago"},
+		{21 * 24 * time.Hour, "3w ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestWriteListTable(t *testing.T) {
+	now := time.Date(2026, 4, 20, 12, 0, 0, 0, time.UTC)
+	worktrees := []worktree.WorktreeInfo{
+		{
+			Name:   "feature",
+			Branch: "worktree-feature",
+			Path:   "/repo/.claude/worktrees/feature",
+			Ports:  worktree.PortRange{Base: 12340, Count: 10},
+			Status: &worktree.WorktreeStatus{
+				Uncommitted: 3,
+				Upstream:    "origin/worktree-feature",
+				Ahead:       2,
+				BaseBranch:  "main",
+				BaseAhead:   5,
+				BaseBehind:  1,
+				LastCommit:  now.Add(-2 * time.Hour),
+			},
+		},
+		{
+			Name:         "stray",
+			Path:         "/repo/.claude/worktrees/stray",
+			Unregistered: true,
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeListTable(buf, worktrees, "main", now); err != nil {
+		t.Fatalf("writeListTable failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d lines:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "VS MAIN") || !strings.Contains(lines[0], "LAST COMMIT") || !strings.Contains(lines[0], "PORTS") {
+		t.Errorf("header missing columns: %q", lines[0])
+	}
+	for _, want := range []string{"feature", "worktree-feature", "3 changed", "↑2", "↑5 ↓1", "2h ago", "12340-12349"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("row %q should contain %q", lines[1], want)
+		}
+	}
+	if !strings.Contains(lines[2], "not a git worktree") {
+		t.Errorf("row %q should flag unregistered directory", lines[2])
+	}
+
+	// Columns are aligned: BRANCH starts at the same offset in every line
+	col := strings.Index(lines[0], "BRANCH")
+	if strings.Index(lines[1], "worktree-feature") != col {
+		t.Errorf("BRANCH column not aligned:\n%s", buf.String())
+	}
+}
+
+func TestList_JSON(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	createWorktreeForBranch(t, repoDir, "json-wt")
+
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		listJSON = false
+		listCmd.Flags().Lookup("json").Changed = false
+	}()
+
+	rootCmd.SetArgs([]string{"list", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("list --json failed: %v\n%s", err, buf.String())
+	}
+
+	var doc listOutput
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if doc.Version != jsonSchemaVersion {
+		t.Errorf("version = %d, want %d", doc.Version, jsonSchemaVersion)
+	}
+	if len(doc.Worktrees) != 1 || doc.Worktrees[0].Name != "json-wt" {
+		t.Fatalf("worktrees = %+v, want json-wt", doc.Worktrees)
+	}
+	if doc.Worktrees[0].Status == nil || doc.Worktrees[0].Status.BaseBranch != "main" {
+		t.Errorf("status = %+v, want status relative to main", doc.Worktrees[0].Status)
+	}
+	if doc.Worktrees[0].Ports.Count == 0 {
+		t.Errorf("ports = %+v, want the worktree's port range", doc.Worktrees[0].Ports)
+	}
+}
+
+func TestList_Format(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	createWorktreeForBranch(t, repoDir, "fmt-wt")
+
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		listFormat = ""
+		listCmd.Flags().Lookup("format").Changed = false
+	}()
+
+	rootCmd.SetArgs([]string{"list", "--format", "{{.Name}} {{.Branch}}"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("list --format failed: %v\n%s", err, buf.String())
+	}
+
+	if buf.String() != "fmt-wt fmt-wt\n" {
+		t.Errorf("output = %q, want %q", buf.String(), "fmt-wt fmt-wt\n")
+	}
+}
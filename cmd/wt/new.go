@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/niref/wt/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newBase      string
+	newPrintPath bool
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create a worktree",
+	Long: `Create a worktree at .claude/worktrees/<name>/ on a new branch worktree-<name>,
+the same layout 'claude --worktree <name>' uses, so Claude Code can pick it up later.
+
+The branch starts at --base (default: HEAD of the main checkout). Files listed in
+.worktreeinclude are copied into the new worktree. With shell integration, wt new
+changes into the worktree.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoRoot, err := worktree.FindRepoRoot(cwd)
+		if err != nil {
+			return fmt.Errorf("not in a git repository")
+		}
+
+		mgr := worktree.NewManager(repoRoot)
+		name := args[0]
+
+		if err := mgr.CreateNew(name, newBase); err != nil {
+			return err
+		}
+
+		if err := mgr.CopyWorktreeInclude(name); err != nil {
+			return fmt.Errorf("copying .worktreeinclude files: %w", err)
+		}
+
+		wtPath := mgr.WorktreePath(name)
+		if newPrintPath {
+			fmt.Fprintln(cmd.OutOrStdout(), wtPath)
+		} else {
+			fmt.Fprintf(cmd.OutOrStdout(), "Created %s on branch %s\n", wtPath, worktree.BranchName(name))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	newCmd.Flags().StringVar(&newBase, "base", "", "Ref to start the branch from (default: HEAD)")
+	newCmd.Flags().BoolVar(&newPrintPath, "print-path", false, "Only print the worktree path")
+	rootCmd.AddCommand(newCmd)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew_CreatesClaudeCompatibleWorktree(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+
+	if err := os.WriteFile(filepath.Join(repoDir, ".worktreeinclude"), []byte("CLAUDE.md\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "CLAUDE.md"), []byte("instructions"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		newPrintPath = false
+		newBase = ""
+	}()
+
+	rootCmd.SetArgs([]string{"new", "--print-path", "--base", "main", "task"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("new failed: %v\n%s", err, buf.String())
+	}
+
+	wtPath := filepath.Join(repoDir, ".claude", "worktrees", "task")
+	if got := strings.TrimSpace(buf.String()); got != wtPath {
+		t.Errorf("output = %q, want %q", got, wtPath)
+	}
+
+	head, err := os.ReadFile(filepath.Join(repoDir, ".git", "worktrees", "task", "HEAD"))
+	if err != nil {
+		t.Fatalf("reading worktree HEAD: %v", err)
+	}
+	if got := strings.TrimSpace(string(head)); got != "ref: refs/heads/worktree-task" {
+		t.Errorf("worktree HEAD = %q, want branch worktree-task", got)
+	}
+
+	content, err := os.ReadFile(filepath.Join(wtPath, "CLAUDE.md"))
+	if err != nil || string(content) != "instructions" {
+		t.Errorf(".worktreeinclude file not copied: %v %q", err, content)
+	}
+}
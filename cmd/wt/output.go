@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/niref/wt/internal/worktree"
+)
+
+// jsonSchemaVersion is the version of the --json output schema.
+// Bump it on any incompatible change (renamed or removed fields).
+const jsonSchemaVersion = 1
+
+// listOutput is the --json document for `wt list`.
+type listOutput struct {
+	Version   int                     `json:"version"`
+	Worktrees []worktree.WorktreeInfo `json:"worktrees"`
+}
+
+// pruneOutput is the --json document for `wt prune --dry-run`.
+type pruneOutput struct {
+	Version int `json:"version"`
+	worktree.PrunePlan
+}
+
+// writeJSON writes v as indented JSON followed by a newline.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeTemplate executes a Go template once per item, each followed by a newline.
+func writeTemplate[T any](w io.Writer, format string, items []T) error {
+	tmpl, err := template.New("format").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return fmt.Errorf("parsing --format template: %w", err)
+	}
+	for _, item := range items {
+		if err := tmpl.Execute(w, item); err != nil {
+			return fmt.Errorf("executing --format template: %w", err)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/niref/wt/internal/worktree"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	worktrees := []worktree.WorktreeInfo{
+		{Name: "feature-a", Branch: "worktree-feature-a"},
+		{Name: "feature-b", Branch: "worktree-feature-b"},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeTemplate(buf, "{{.Name}} {{.Branch}}", worktrees); err != nil {
+		t.Fatalf("writeTemplate failed: %v", err)
+	}
+
+	want := "feature-a worktree-feature-a\nfeature-b worktree-feature-b\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteTemplate_Errors(t *testing.T) {
+	worktrees := []worktree.WorktreeInfo{{Name: "feature-a"}}
+
+	if err := writeTemplate(new(bytes.Buffer), "{{.Name", worktrees); err == nil {
+		t.Error("expected parse error for unterminated action")
+	}
+	if err := writeTemplate(new(bytes.Buffer), "{{.NoSuchField}}", worktrees); err == nil {
+		t.Error("expected execution error for unknown field")
+	}
+}
+
+func TestWriteJSON_PruneOutputFlattensCandidates(t *testing.T) {
+	out := pruneOutput{
+		Version: jsonSchemaVersion,
+		PrunePlan: worktree.PrunePlan{
+			Candidates: []worktree.PruneCandidate{
+				{WorktreeInfo: worktree.WorktreeInfo{Name: "gone", Branch: "gone"}, Reason: worktree.ReasonRemoteGone},
+			},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeJSON(buf, out); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+
+	var doc struct {
+		Version    int              `json:"version"`
+		Candidates []map[string]any `json:"candidates"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if doc.Version != jsonSchemaVersion {
+		t.Errorf("version = %d, want %d", doc.Version, jsonSchemaVersion)
+	}
+	if len(doc.Candidates) != 1 {
+		t.Fatalf("expected 1 candidate, got %d", len(doc.Candidates))
+	}
+	c := doc.Candidates[0]
+	if c["name"] != "gone" || c["reason"] != worktree.ReasonRemoteGone {
+		t.Errorf("candidate = %v, want name and reason at top level", c)
+	}
+	if strings.Contains(buf.String(), "WorktreeInfo") {
+		t.Errorf("embedded struct should be flattened, got: %s", buf.String())
+	}
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/charmbracelet/huh"
 	"github.com/niref/wt/internal/worktree"
 )
@@ -48,3 +52,64 @@ func runInteractivePicker(repoRoot string, mgr *worktree.Manager) (string, error
 
 	return selected, nil
 }
+
+// pruneOption is one entry in the interactive prune picker.
+type pruneOption struct {
+	Label    string // Name annotated with reason, issues and age
+	Name     string // Worktree name returned when selected
+	Selected bool   // Pre-selected because removing it loses nothing
+}
+
+// buildPruneOptions creates the entries for the interactive prune picker.
+// issues maps candidate names to their removalIssues; candidates without issues are pre-selected.
+func buildPruneOptions(candidates []worktree.PruneCandidate, issues map[string][]string, now time.Time) []pruneOption {
+	options := make([]pruneOption, len(candidates))
+	for i, c := range candidates {
+		notes := append([]string{c.Reason}, issues[c.Name]...)
+		if c.Status != nil && !c.Status.LastCommit.IsZero() {
+			notes = append(notes, "last commit "+formatAge(now.Sub(c.Status.LastCommit)))
+		}
+		options[i] = pruneOption{
+			Label:    fmt.Sprintf("%s (%s)", c.Name, strings.Join(notes, ", ")),
+			Name:     c.Name,
+			Selected: len(issues[c.Name]) == 0,
+		}
+	}
+	return options
+}
+
+// runPrunePicker displays a multi-select of prune candidates and returns the chosen ones.
+func runPrunePicker(candidates []worktree.PruneCandidate, issues map[string][]string) ([]worktree.PruneCandidate, error) {
+	options := buildPruneOptions(candidates, issues, time.Now())
+
+	var selected []string
+	huhOptions := make([]huh.Option[string], len(options))
+	for i, opt := range options {
+		huhOptions[i] = huh.NewOption(opt.Label, opt.Name).Selected(opt.Selected)
+		if opt.Selected {
+			selected = append(selected, opt.Name)
+		}
+	}
+
+	err := huh.NewMultiSelect[string]().
+		Title("Select worktrees to prune").
+		Description("Worktrees without uncommitted changes or unpushed commits are pre-selected").
+		Options(huhOptions...).
+		Value(&selected).
+		Run()
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := make(map[string]bool, len(selected))
+	for _, name := range selected {
+		chosen[name] = true
+	}
+	var result []worktree.PruneCandidate
+	for _, c := range candidates {
+		if chosen[c.Name] {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/niref/wt/internal/worktree"
 )
@@ -53,3 +54,36 @@ func TestBuildPickerOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildPruneOptions(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	candidates := []worktree.PruneCandidate{
+		{
+			WorktreeInfo: worktree.WorktreeInfo{Name: "gone", Branch: "worktree-gone",
+				Status: &worktree.WorktreeStatus{LastCommit: now.Add(-3 * 24 * time.Hour)}},
+			Reason: worktree.ReasonRemoteGone,
+		},
+		{
+			WorktreeInfo: worktree.WorktreeInfo{Name: "wip", Branch: "worktree-wip",
+				Status: &worktree.WorktreeStatus{Uncommitted: 2}},
+			Reason: "idle for 16d",
+		},
+	}
+	issues := map[string][]string{
+		"wip": {"uncommitted changes", "unpushed commits"},
+	}
+
+	got := buildPruneOptions(candidates, issues, now)
+	want := []pruneOption{
+		{Label: "gone (remote branch deleted, last commit 3d ago)", Name: "gone", Selected: true},
+		{Label: "wip (idle for 16d, uncommitted changes, unpushed commits)", Name: "wip", Selected: false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("buildPruneOptions() returned %d options, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("buildPruneOptions()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -4,16 +4,24 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/niref/wt/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
 var (
-	pruneForce   bool
-	pruneNoFetch bool
-	pruneDryRun  bool
+	pruneForce       bool
+	pruneNoFetch     bool
+	pruneDryRun      bool
+	pruneJSON        bool
+	pruneFormat      string
+	pruneJobs        int
+	pruneMerged      bool
+	pruneOlder       string
+	pruneInteractive bool
 )
 
 var pruneCmd = &cobra.Command{
@@ -22,8 +30,38 @@ var pruneCmd = &cobra.Command{
 	Long: `Remove worktrees whose branches have been deleted from the remote (merged or manually deleted).
 
 Only considers branches with upstream tracking configured - local-only branches are never pruned.
-Use --dry-run to preview what would be removed.`,
+
+With --merged, also removes worktrees whose branch is already in the main branch (its
+upstream, e.g. origin/main, when configured), even if the remote branch still exists or was
+never pushed. Fast-forward and merge commits, rebased or cherry-picked commits, and squash
+merges are detected. The reason is shown for each candidate.
+
+With --older-than (e.g. 14d, 2w, 36h), also removes worktrees with no activity for that
+long, based on the last commit date and the newest change to a modified or untracked
+file (ignored files don't count) or to the worktree's git index.
+
+With -i, shows all candidates in a multi-select annotated with their reason, uncommitted
+changes, unpushed commits and age. Candidates that can be removed without losing work are
+pre-selected; the selection is removed without further prompts.
+
+Use --dry-run to preview what would be removed. With --dry-run, --json prints the
+prune plan as a versioned JSON document and --format renders each candidate with a
+Go template, e.g. --format '{{.Name}} {{.Reason}}'.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		machineOutput := pruneJSON || pruneFormat != ""
+		if machineOutput && !pruneDryRun {
+			return fmt.Errorf("--json and --format require --dry-run")
+		}
+
+		var olderThan time.Duration
+		if pruneOlder != "" {
+			d, err := parseAge(pruneOlder)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+			olderThan = d
+		}
+
 		cwd, err := os.Getwd()
 		if err != nil {
 			return err
@@ -49,28 +87,37 @@ Use --dry-run to preview what would be removed.`,
 			return err
 		}
 
-		if len(worktrees) == 0 {
+		if len(worktrees) == 0 && !machineOutput {
 			fmt.Fprintln(cmd.OutOrStdout(), "No worktrees found")
 			return nil
 		}
 
-		// Find prune candidates: worktrees whose branch has upstream tracking
-		// but the remote branch no longer exists
-		var candidates []worktree.WorktreeInfo
-		for _, wt := range worktrees {
-			if wt.Branch == "" {
-				continue
+		opts := worktree.PruneOptions{Merged: pruneMerged, OlderThan: olderThan, Jobs: pruneJobs}
+		if pruneMerged {
+			mainBranch, err := worktree.GetMainBranch(repoRoot)
+			if err != nil {
+				return fmt.Errorf("determining main branch: %w", err)
 			}
-			upstream := mgr.BranchUpstream(wt.Branch)
-			if upstream == "" {
-				// No upstream tracking - skip (local-only branch)
-				continue
+			opts.BaseBranch = mainBranch
+			if upstream := mgr.BranchUpstream(mainBranch); upstream != "" {
+				opts.BaseBranch = upstream
 			}
-			if mgr.RemoteBranchExists(wt.Branch) {
-				// Remote branch still exists - not a prune candidate
-				continue
+			if !worktree.SquashDetectionSupported() {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Warning: git 2.38 or newer is needed to detect squash merges; squash-merged worktrees are not pruned")
+			}
+		}
+
+		plan, err := mgr.PlanPrune(worktrees, opts)
+		if err != nil {
+			return err
+		}
+		candidates := plan.Candidates
+
+		if machineOutput {
+			if pruneJSON {
+				return writeJSON(cmd.OutOrStdout(), pruneOutput{Version: jsonSchemaVersion, PrunePlan: plan})
 			}
-			candidates = append(candidates, wt)
+			return writeTemplate(cmd.OutOrStdout(), pruneFormat, candidates)
 		}
 
 		if len(candidates) == 0 {
@@ -82,41 +129,42 @@ Use --dry-run to preview what would be removed.`,
 		if pruneDryRun {
 			fmt.Fprintln(cmd.OutOrStdout(), "Would prune (dry-run):")
 			for _, c := range candidates {
-				fmt.Fprintf(cmd.OutOrStdout(), "  - %s (%s)\n", c.Name, c.Branch)
+				fmt.Fprintf(cmd.OutOrStdout(), "  - %s (%s): %s\n", c.Name, c.Branch, c.Reason)
 			}
 			return nil
 		}
 
+		// Check for issues that require prompting (status collected by PlanPrune)
+		issues := make(map[string][]string, len(candidates))
+		for _, candidate := range candidates {
+			status := *candidate.Status
+			issues[candidate.Name] = removalIssues(status.Dirty(), hasUnpushedCommits(mgr, candidate.WorktreeInfo, status))
+		}
+
+		if pruneInteractive {
+			candidates, err = runPrunePicker(candidates, issues)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Prune each candidate
-		var pruned []string
+		var pruned []worktree.PruneCandidate
 		var errors []string
+		reader := bufio.NewReader(cmd.InOrStdin())
 
 		for _, candidate := range candidates {
 			name := candidate.Name
-			wtPath := candidate.Path
-
-			// Check for issues that require prompting
-			hasUncommitted := mgr.HasUncommittedChanges(wtPath)
-			hasUnpushed := mgr.HasUnpushedCommits(candidate.Branch)
 
-			if (hasUncommitted || hasUnpushed) && !pruneForce {
-				issues := []string{}
-				if hasUncommitted {
-					issues = append(issues, "uncommitted changes")
-				}
-				if hasUnpushed {
-					issues = append(issues, "unpushed commits")
-				}
-				fmt.Fprintf(cmd.OutOrStdout(), "Remove %s? It has %s [y/n]: ", name, strings.Join(issues, " and "))
-
-				reader := bufio.NewReader(os.Stdin)
-				input, err := reader.ReadString('\n')
+			// Interactive selections were already reviewed with their issues shown
+			if len(issues[name]) > 0 && !pruneForce && !pruneInteractive {
+				label := fmt.Sprintf("%s (%s)", name, candidate.Reason)
+				ok, err := confirmRemoval(cmd.OutOrStdout(), reader, label, issues[name])
 				if err != nil {
 					errors = append(errors, fmt.Sprintf("%s: failed to read input: %v", name, err))
 					continue
 				}
-				input = strings.TrimSpace(strings.ToLower(input))
-				if input != "y" && input != "yes" {
+				if !ok {
 					fmt.Fprintf(cmd.OutOrStdout(), "Skipping %s\n", name)
 					continue
 				}
@@ -128,15 +176,16 @@ Use --dry-run to preview what would be removed.`,
 				continue
 			}
 
-			pruned = append(pruned, name)
+			pruned = append(pruned, candidate)
 		}
 
 		// Print summary
 		if len(pruned) > 0 {
 			fmt.Fprintf(cmd.OutOrStdout(), "Pruned %d worktree(s):\n", len(pruned))
 			for _, p := range pruned {
-				fmt.Fprintf(cmd.OutOrStdout(), "  - %s\n", p)
+				fmt.Fprintf(cmd.OutOrStdout(), "  - %s (%s)\n", p.Name, p.Reason)
 			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Restore with 'wt restore <name>' (see 'wt archive list')")
 		}
 
 		if len(errors) > 0 {
@@ -158,5 +207,44 @@ func init() {
 	pruneCmd.Flags().BoolVarP(&pruneForce, "force", "f", false, "Force removal even if worktrees have uncommitted changes")
 	pruneCmd.Flags().BoolVar(&pruneNoFetch, "no-fetch", false, "Skip git fetch --prune (use current remote refs)")
 	pruneCmd.Flags().BoolVarP(&pruneDryRun, "dry-run", "n", false, "Show what would be pruned without doing it")
+	pruneCmd.Flags().BoolVar(&pruneJSON, "json", false, "With --dry-run, output the prune plan as JSON")
+	pruneCmd.Flags().StringVar(&pruneFormat, "format", "", "With --dry-run, format each candidate with a Go template")
+	pruneCmd.MarkFlagsMutuallyExclusive("json", "format")
+	pruneCmd.Flags().BoolVar(&pruneMerged, "merged", false, "Also prune worktrees whose branch is merged into the main branch")
+	pruneCmd.Flags().StringVar(&pruneOlder, "older-than", "", "Also prune worktrees idle for this long (e.g. 14d, 2w, 36h)")
+	pruneCmd.Flags().BoolVarP(&pruneInteractive, "interactive", "i", false, "Choose candidates to prune from a multi-select list")
+	pruneCmd.MarkFlagsMutuallyExclusive("interactive", "dry-run")
+	pruneCmd.Flags().IntVarP(&pruneJobs, "jobs", "j", worktree.DefaultJobs(), "Number of worktrees to inspect in parallel")
 	rootCmd.AddCommand(pruneCmd)
 }
+
+// parseAge parses a duration that may use d (days) and w (weeks) units in
+// addition to those accepted by time.ParseDuration, e.g. "14d" or "2w".
+func parseAge(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	num := s
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit, num = 24*time.Hour, strings.TrimSuffix(s, "d")
+	case strings.HasSuffix(s, "w"):
+		unit, num = 7*24*time.Hour, strings.TrimSuffix(s, "w")
+	}
+
+	var d time.Duration
+	if unit > 0 {
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d = time.Duration(n) * unit
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", s)
+	}
+	return d, nil
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/niref/wt/internal/worktree"
 )
@@ -514,3 +516,249 @@ func TestPrune_PromptsForUncommittedChanges(t *testing.T) {
 		t.Errorf("output should mention the worktree name, got: %s", output)
 	}
 }
+
+func TestPrune_DryRunJSON(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+
+	cmds := [][]string{
+		{"git", "checkout", "-b", "json-gone"},
+		{"git", "commit", "--allow-empty", "-m", "gone commit"},
+		{"git", "push", "-u", "origin", "json-gone"},
+		{"git", "checkout", "main"},
+	}
+	for _, args := range cmds {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = repoDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+	createWorktreeForBranch(t, repoDir, "json-gone")
+
+	cmd := exec.Command("git", "push", "origin", "--delete", "json-gone")
+	cmd.Dir = repoDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("push delete failed: %v\n%s", err, out)
+	}
+
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		pruneDryRun = false
+		pruneJSON = false
+		pruneCmd.Flags().Lookup("json").Changed = false
+	}()
+
+	rootCmd.SetArgs([]string{"prune", "--dry-run", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("prune --dry-run --json failed: %v\n%s", err, buf.String())
+	}
+
+	var doc struct {
+		Version    int                       `json:"version"`
+		Candidates []worktree.PruneCandidate `json:"candidates"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if doc.Version != jsonSchemaVersion {
+		t.Errorf("version = %d, want %d", doc.Version, jsonSchemaVersion)
+	}
+	if len(doc.Candidates) != 1 || doc.Candidates[0].Name != "json-gone" {
+		t.Fatalf("candidates = %+v, want json-gone", doc.Candidates)
+	}
+	if doc.Candidates[0].Reason != worktree.ReasonRemoteGone {
+		t.Errorf("reason = %q, want %q", doc.Candidates[0].Reason, worktree.ReasonRemoteGone)
+	}
+}
+
+func TestPrune_JSONRequiresDryRun(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		pruneJSON = false
+		pruneCmd.Flags().Lookup("json").Changed = false
+	}()
+
+	rootCmd.SetArgs([]string{"prune", "--json"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--dry-run") {
+		t.Errorf("expected error requiring --dry-run, got: %v", err)
+	}
+}
+
+func TestPrune_Merged_DryRunShowsReason(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+
+	// Branch pushed and squash-merged on "the forge"; remote branch lingers
+	wtPath := createWorktreeForBranch(t, repoDir, "squashed-feature")
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(wtPath, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		dir  string
+		args []string
+	}{
+		{wtPath, []string{"git", "add", "a.txt"}},
+		{wtPath, []string{"git", "commit", "-m", "feature part 1"}},
+		{wtPath, []string{"git", "add", "b.txt"}},
+		{wtPath, []string{"git", "commit", "-m", "feature part 2"}},
+		{wtPath, []string{"git", "push", "-u", "origin", "squashed-feature"}},
+		{repoDir, []string{"git", "merge", "--squash", "squashed-feature"}},
+		{repoDir, []string{"git", "commit", "-m", "Squashed feature"}},
+		{repoDir, []string{"git", "push", "origin", "main"}},
+	} {
+		cmd := exec.Command(c.args[0], c.args[1:]...)
+		cmd.Dir = c.dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", c.args, err, out)
+		}
+	}
+
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		pruneDryRun = false
+		pruneMerged = false
+	}()
+
+	// Without --merged the lingering remote branch keeps it
+	rootCmd.SetArgs([]string{"prune", "--dry-run"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("prune --dry-run failed: %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "Nothing to prune") {
+		t.Errorf("without --merged nothing should be pruned, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	rootCmd.SetArgs([]string{"prune", "--dry-run", "--merged"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("prune --dry-run --merged failed: %v\n%s", err, buf.String())
+	}
+	output := buf.String()
+	if !strings.Contains(output, "squashed-feature") || !strings.Contains(output, "squash-merged into origin/main") {
+		t.Errorf("output should list squashed-feature with its reason, got: %s", output)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"14d", 14 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"xd", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAge(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPrune_OlderThan_DryRunShowsIdle(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+
+	// Pushed branch that still exists on the remote, last touched 20 days ago
+	wtPath := createWorktreeForBranch(t, repoDir, "stale-work")
+	old := time.Now().Add(-20 * 24 * time.Hour)
+	date := old.Format(time.RFC3339)
+	cmd := exec.Command("git", "commit", "--allow-empty", "-m", "old work")
+	cmd.Dir = wtPath
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+	cmd = exec.Command("git", "push", "-u", "origin", "stale-work")
+	cmd.Dir = wtPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("push failed: %v\n%s", err, out)
+	}
+	err := filepath.WalkDir(wtPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The commit also touched HEAD and the index in the worktree's admin dir
+	cmd = exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = wtPath
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"HEAD", "index"} {
+		if err := os.Chtimes(filepath.Join(strings.TrimSpace(string(out)), name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Recently active worktree
+	createWorktreeForBranch(t, repoDir, "fresh-work")
+
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+		pruneDryRun = false
+		pruneOlder = ""
+	}()
+
+	rootCmd.SetArgs([]string{"prune", "--dry-run", "--older-than", "14d"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("prune --older-than failed: %v\n%s", err, buf.String())
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "stale-work") || !strings.Contains(output, "idle for 20d") {
+		t.Errorf("output should list stale-work idle for 20d, got: %s", output)
+	}
+	if strings.Contains(output, "fresh-work") {
+		t.Errorf("recently active worktree should not be listed, got: %s", output)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/niref/wt/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var restoreID string
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Restore a removed worktree from its archive",
+	Long: `Restore a worktree removed by wt rm or wt prune from its archive.
+
+Every removal first saves an archive under refs/wt/archive/<name>/: the branch tip
+and a snapshot of uncommitted and untracked files. wt restore recreates the worktree
+and its branch at the archived commit and writes the uncommitted files back as
+unstaged changes. Ignored files are not archived; files listed in .worktreeinclude
+are copied again.
+
+Restores the newest archive unless --id is given (see wt archive list).`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoRoot, err := worktree.FindRepoRoot(cwd)
+		if err != nil {
+			return fmt.Errorf("not in a git repository")
+		}
+
+		mgr := worktree.NewManager(repoRoot)
+		name := args[0]
+
+		archive, err := mgr.FindArchive(name, restoreID)
+		if err != nil {
+			return fmt.Errorf("%s: %w (see 'wt archive list')", name, err)
+		}
+
+		if err := mgr.Restore(archive); err != nil {
+			return err
+		}
+
+		if err := mgr.CopyWorktreeInclude(name); err != nil {
+			return fmt.Errorf("copying .worktreeinclude files: %w", err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Restored %s on branch %s\n", mgr.WorktreePath(name), archive.Branch)
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().StringVar(&restoreID, "id", "", "Archive ID to restore (default: newest)")
+	rootCmd.AddCommand(restoreCmd)
+}
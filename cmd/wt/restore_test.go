@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/niref/wt/internal/worktree"
+)
+
+func TestRestore_AfterRm(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	wtPath := createWorktreeForBranch(t, repoDir, "restore-me")
+	if err := os.WriteFile(filepath.Join(wtPath, "notes.txt"), []byte("wip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if output, err := runRm(t, repoDir, "", "--force", "restore-me"); err != nil {
+		t.Fatalf("rm failed: %v\n%s", err, output)
+	}
+
+	origDir, _ := os.Getwd()
+	os.Chdir(repoDir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+	}()
+
+	rootCmd.SetArgs([]string{"archive", "list"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("archive list failed: %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "restore-me") || !strings.Contains(buf.String(), "uncommitted") {
+		t.Errorf("archive list should show the removed worktree, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	rootCmd.SetArgs([]string{"restore", "restore-me"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("restore failed: %v\n%s", err, buf.String())
+	}
+
+	if !worktree.NewManager(repoDir).Exists("restore-me") {
+		t.Fatal("worktree should be restored")
+	}
+	if got, err := os.ReadFile(filepath.Join(wtPath, "notes.txt")); err != nil || string(got) != "wip" {
+		t.Errorf("untracked file = %q, %v; want wip", got, err)
+	}
+
+	buf.Reset()
+	rootCmd.SetArgs([]string{"restore", "restore-me"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("restoring twice should fail: the archive is consumed")
+	}
+}
+
+func TestWriteArchiveTable(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	archives := []worktree.Archive{
+		{Name: "feat", ID: "1736503200", Branch: "worktree-feat", Dirty: true, Created: now.Add(-2 * time.Hour)},
+		{Name: "detached", ID: "1736413200", Branch: "HEAD", Created: now.Add(-26 * time.Hour)},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeArchiveTable(buf, archives, now); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "NAME      BRANCH         CHANGES      ARCHIVED  ID\n" +
+		"feat      worktree-feat  uncommitted  2h ago    1736503200\n" +
+		"detached  -              clean        1d ago    1736413200\n"
+	if buf.String() != want {
+		t.Errorf("writeArchiveTable() =\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/niref/wt/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rmForce        bool
+	rmKeepBranch   bool
+	rmAllowCurrent bool
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <name>...",
+	Short: "Remove worktrees",
+	Long: `Remove one or more worktrees by name and force-delete their branches.
+
+Prompts before removing a worktree with uncommitted changes or with commits that
+are not on any remote. Use --keep-branch to keep the local branch (then unpushed
+commits are not at risk), and --force to skip prompts.
+
+Refuses to remove the worktree you are currently in unless --allow-current is given.
+
+Each worktree is archived before removal; bring it back with 'wt restore <name>'.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		repoRoot, err := worktree.FindRepoRoot(cwd)
+		if err != nil {
+			return fmt.Errorf("not in a git repository")
+		}
+
+		mgr := worktree.NewManager(repoRoot)
+
+		worktrees, err := mgr.List()
+		if err != nil {
+			return err
+		}
+		byName := make(map[string]worktree.WorktreeInfo, len(worktrees))
+		for _, wt := range worktrees {
+			byName[wt.Name] = wt
+		}
+
+		var removed []string
+		var errors []string
+		reader := bufio.NewReader(cmd.InOrStdin())
+
+		for _, name := range args {
+			wt, ok := byName[name]
+			if !ok || wt.Missing || wt.Unregistered {
+				errors = append(errors, fmt.Sprintf("%s: %v", name, worktree.ErrWorktreeNotFound))
+				continue
+			}
+
+			inside := isInside(cwd, wt.Path)
+			if inside && !rmAllowCurrent {
+				errors = append(errors, fmt.Sprintf("%s: you are inside this worktree (use --allow-current to remove it anyway)", name))
+				continue
+			}
+
+			if !rmForce {
+				status := mgr.Status(wt, "")
+				hasUnpushed := !rmKeepBranch && hasUnpushedCommits(mgr, wt, status)
+				if issues := removalIssues(status.Dirty(), hasUnpushed); len(issues) > 0 {
+					ok, err := confirmRemoval(cmd.OutOrStdout(), reader, name, issues)
+					if err != nil {
+						errors = append(errors, fmt.Sprintf("%s: failed to read input: %v", name, err))
+						continue
+					}
+					if !ok {
+						fmt.Fprintf(cmd.OutOrStdout(), "Skipping %s\n", name)
+						continue
+					}
+				}
+			}
+
+			// Force: true because user confirmed or --force flag
+			opts := worktree.RemoveOptions{Force: true, KeepBranch: rmKeepBranch}
+			if err := mgr.RemoveWithOptions(name, opts); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: remove worktree: %v", name, err))
+				continue
+			}
+
+			removed = append(removed, name)
+			if inside {
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed the current worktree; cd %s\n", repoRoot)
+			}
+		}
+
+		if len(removed) > 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d worktree(s):\n", len(removed))
+			for _, r := range removed {
+				fmt.Fprintf(cmd.OutOrStdout(), "  - %s\n", r)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Restore with 'wt restore <name>' (see 'wt archive list')")
+		}
+
+		if len(errors) > 0 {
+			fmt.Fprintln(cmd.ErrOrStderr(), "\nErrors:")
+			for _, e := range errors {
+				fmt.Fprintf(cmd.ErrOrStderr(), "  %s\n", e)
+			}
+			return fmt.Errorf("failed to remove %d worktree(s)", len(errors))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rmCmd.Flags().BoolVarP(&rmForce, "force", "f", false, "Remove without prompting about uncommitted changes or unpushed commits")
+	rmCmd.Flags().BoolVar(&rmKeepBranch, "keep-branch", false, "Keep the local branch")
+	rmCmd.Flags().BoolVar(&rmAllowCurrent, "allow-current", false, "Allow removing the worktree you are currently in")
+	rootCmd.AddCommand(rmCmd)
+}
+
+// isInside reports whether dir is path or a directory below it.
+func isInside(dir, path string) bool {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	rel, err := filepath.Rel(path, dir)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/niref/wt/internal/worktree"
+)
+
+// runRm executes `wt rm` with args from dir, feeding stdin, and resets rm flags afterwards.
+func runRm(t *testing.T, dir, stdin string, args ...string) (string, error) {
+	t.Helper()
+	origDir, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(origDir)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetIn(strings.NewReader(stdin))
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetIn(nil)
+		rootCmd.SetArgs(nil)
+		rmForce = false
+		rmKeepBranch = false
+		rmAllowCurrent = false
+	}()
+
+	rootCmd.SetArgs(append([]string{"rm"}, args...))
+	err := rootCmd.Execute()
+	return buf.String(), err
+}
+
+func TestRm_RemovesWorktreeAndBranch(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	createWorktreeForBranch(t, repoDir, "rm-a")
+	createWorktreeForBranch(t, repoDir, "rm-b")
+	mgr := worktree.NewManager(repoDir)
+
+	output, err := runRm(t, repoDir, "", "--force", "rm-a", "rm-b")
+	if err != nil {
+		t.Fatalf("rm failed: %v\n%s", err, output)
+	}
+
+	for _, name := range []string{"rm-a", "rm-b"} {
+		if mgr.Exists(name) {
+			t.Errorf("%s worktree should be removed", name)
+		}
+		if mgr.BranchExists(name) {
+			t.Errorf("%s branch should be deleted", name)
+		}
+	}
+	if !strings.Contains(output, "Removed 2 worktree(s)") {
+		t.Errorf("output should summarize removals, got: %s", output)
+	}
+}
+
+func TestRm_KeepBranch(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	createWorktreeForBranch(t, repoDir, "keep-me")
+	mgr := worktree.NewManager(repoDir)
+
+	if output, err := runRm(t, repoDir, "", "--keep-branch", "keep-me"); err != nil {
+		t.Fatalf("rm failed: %v\n%s", err, output)
+	}
+
+	if mgr.Exists("keep-me") {
+		t.Error("worktree should be removed")
+	}
+	if !mgr.BranchExists("keep-me") {
+		t.Error("branch should be kept with --keep-branch")
+	}
+}
+
+func TestRm_PromptsForLocalCommits(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	wtPath := createWorktreeForBranch(t, repoDir, "local-work")
+	mgr := worktree.NewManager(repoDir)
+
+	cmd := exec.Command("git", "commit", "--allow-empty", "-m", "never pushed")
+	cmd.Dir = wtPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+
+	output, err := runRm(t, repoDir, "n\n", "local-work")
+	if err != nil {
+		t.Fatalf("rm failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(output, "unpushed commits") || !strings.Contains(output, "Skipping local-work") {
+		t.Errorf("output should prompt about unpushed commits and skip, got: %s", output)
+	}
+	if !mgr.Exists("local-work") {
+		t.Error("worktree should be kept after answering no")
+	}
+
+	output, err = runRm(t, repoDir, "y\n", "local-work")
+	if err != nil {
+		t.Fatalf("rm failed: %v\n%s", err, output)
+	}
+	if mgr.Exists("local-work") {
+		t.Error("worktree should be removed after answering yes")
+	}
+}
+
+func TestRm_PromptsForUncommittedChanges(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	wtPath := createWorktreeForBranch(t, repoDir, "dirty-rm")
+	mgr := worktree.NewManager(repoDir)
+
+	if err := os.WriteFile(filepath.Join(wtPath, "scratch.txt"), []byte("wip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := runRm(t, repoDir, "n\n", "dirty-rm")
+	if err != nil {
+		t.Fatalf("rm failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(output, "uncommitted changes") {
+		t.Errorf("output should mention uncommitted changes, got: %s", output)
+	}
+	if !mgr.Exists("dirty-rm") {
+		t.Error("worktree should be kept after answering no")
+	}
+}
+
+func TestRm_RefusesCurrentWorktree(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+	wtPath := createWorktreeForBranch(t, repoDir, "here")
+	mgr := worktree.NewManager(repoDir)
+
+	output, err := runRm(t, wtPath, "", "--force", "here")
+	if err == nil {
+		t.Fatalf("rm should fail for the current worktree, got: %s", output)
+	}
+	if !strings.Contains(output, "--allow-current") {
+		t.Errorf("output should suggest --allow-current, got: %s", output)
+	}
+	if !mgr.Exists("here") {
+		t.Error("current worktree should not be removed")
+	}
+
+	output, err = runRm(t, wtPath, "", "--force", "--allow-current", "here")
+	if err != nil {
+		t.Fatalf("rm --allow-current failed: %v\n%s", err, output)
+	}
+	if mgr.Exists("here") {
+		t.Error("worktree should be removed with --allow-current")
+	}
+}
+
+func TestRm_UnknownWorktree(t *testing.T) {
+	repoDir, _ := setupTestRepoWithRemote(t)
+
+	output, err := runRm(t, repoDir, "", "nope")
+	if err == nil {
+		t.Fatal("expected error for unknown worktree")
+	}
+	if !strings.Contains(output, "nope") {
+		t.Errorf("output should name the missing worktree, got: %s", output)
+	}
+}
+
+func TestIsInside(t *testing.T) {
+	tests := []struct {
+		dir, path string
+		want      bool
+	}{
+		{"/repo/.claude/worktrees/a", "/repo/.claude/worktrees/a", true},
+		{"/repo/.claude/worktrees/a/sub", "/repo/.claude/worktrees/a", true},
+		{"/repo/.claude/worktrees/ab", "/repo/.claude/worktrees/a", false},
+		{"/repo", "/repo/.claude/worktrees/a", false},
+		{"/repo/..foo", "/repo", true},
+	}
+	for _, tt := range tests {
+		if got := isInside(tt.dir, tt.path); got != tt.want {
+			t.Errorf("isInside(%q, %q) = %v, want %v", tt.dir, tt.path, got, tt.want)
+		}
+	}
+}
@@ -2,122 +2,565 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/niref/wt/internal/sandbox"
 	"github.com/niref/wt/internal/worktree"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	sandboxMounts   []string
-	sandboxNoClaude bool
-	sandboxNoMise   bool
-	sandboxImage    string
+	sandboxMounts              []string
+	sandboxNoClaude            bool
+	sandboxNoMise              bool
+	sandboxImage               string
+	sandboxRoGit               bool
+	sandboxRuntime             string
+	sandboxNetwork             string
+	sandboxAllow               []string
+	sandboxLimits              sandbox.Limits
+	sandboxPrompt              string
+	sandboxPromptF             string
+	sandboxNoDevcontainer      bool
+	sandboxDevcontainerRunArgs bool
+	sandboxClaudeHome          string
+	sandboxAPIKeyFrom          string
+	sandboxOAuthFrom           string
+	sandboxSSHAgent            bool
+	sandboxGitCreds            bool
+	sandboxPublish             bool
+	sandboxEnv                 []string
+	sandboxEnvFiles            []string
+	sandboxAudit               bool
 )
 
 var sandboxCmd = &cobra.Command{
 	Use:   "sandbox [branch]",
 	Short: "Run Claude Code in a sandboxed container",
-	Long:  `Start a Podman container with the worktree mounted and run Claude with --dangerously-skip-permissions.`,
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Start a Podman or Docker container with the worktree mounted and run Claude with --dangerously-skip-permissions.
+
+For linked worktrees the container gets a private copy of the repository's refs, so
+commits work but can only move the worktree's own branch. When the container exits,
+that branch (and any newly created branches) are copied back to the repository.
+Use --readonly-git to mount the main .git read-only instead.
+
+By default Claude gets a throwaway configuration directory seeded with the host's
+settings, CLAUDE.md, commands and agents but no login; pass an API key or OAuth
+token with --api-key-from or --oauth-token-from (env:NAME, file:PATH or
+cmd:COMMAND, e.g. "cmd:pass show anthropic"), or with "api_key_from" or
+"oauth_token_from" in your own sandbox.json in $XDG_CONFIG_HOME/wt (by default
+~/.config/wt); .wt/sandbox.json, which comes with the repository, may not set
+them. Secrets reach the container through a private env file, not the command
+line. --claude-home=host mounts ~/.claude and ~/.claude.json read-write instead,
+so Claude uses the host login, but the agent can then change settings and hooks
+that Claude on the host runs.
+
+The container runtime is detected automatically (Podman preferred); use --runtime to choose.
+
+--network controls network access: full (default), none, or allowlist. In allowlist
+mode the container's HTTP(S)_PROXY points at a proxy run by wt on the host that only
+lets through the Anthropic API, common package registries and hosts added with
+--allow-host or "allow_hosts" in .wt/sandbox.json; denied requests are logged to
+egress.log in wt's state directory ($XDG_STATE_HOME/wt, by default
+~/.local/state/wt), where the git bridge, --audit and --prompt keep their records
+too. The container runs on an internal network whose only other member is a relay
+to that proxy (and the git bridge), so clients that ignore the proxy variables
+reach nothing. This needs a Linux host.
+
+The container has no git credentials by default. --git-credentials lets it fetch
+from and push to the repository's HTTPS remotes through a bridge run by wt on the
+host, which adds the host's credentials (from 'git credential fill'), so tokens
+never enter the container. It only forwards git's fetch and push requests for
+those remotes, behind a random per-session path. The bridge refuses pushes that
+would change any ref but the worktree's own branch; refusals are logged to
+git-bridge.log in the state directory. --ssh-agent forwards the host's SSH agent
+for SSH remotes; wt cannot filter SSH traffic, so the agent can then push
+anything your keys allow. Both can be enabled per repo in .wt/sandbox.json
+("ssh_agent", "git_credentials").
+
+TERM, COLORTERM, LANG, LC_ALL, LC_CTYPE and TZ are passed from the host (add
+more with "pass_env" in .wt/sandbox.json). Set other variables with --env
+KEY=VALUE (--env KEY for the host's value), --env-file, or in the worktree's
+.wt/env file (KEY=VALUE lines; later sources win). These reach the container
+through a private env file, not the command line. Since the agent can edit
+.wt/env, it may only set values, not read host variables.
+
+Each worktree has a range of ports of its own, derived from its name and shown by
+'wt list', so dev servers in different worktrees don't collide; a worktree whose
+range another one already has gets the next free range. The container gets
+it as WT_PORT_BASE, WT_PORT_END and WT_PORT_COUNT; --publish (or "publish" in
+.wt/sandbox.json) publishes the range on 127.0.0.1 with the same port numbers.
+
+--cpus, --memory, --pids-limit and --disk-quota limit the container's resources;
+defaults can be set per repo in .wt/sandbox.json ("cpus", "memory", "pids_limit",
+"disk_quota").
+
+If the repository has a .devcontainer/devcontainer.json, its image or Dockerfile
+(unless --image is given), containerEnv, mounts, runArgs, forwardPorts and
+postCreateCommand are used; the image must provide Claude Code. Forwarded ports
+are published at the worktree's ports, from $WT_PORT_BASE on. runArgs that change
+the network, privileges or mounts, and mounts of host paths outside the worktree,
+need --devcontainer-run-args; mounted host paths are not relabeled for SELinux.
+Use --no-devcontainer to ignore the file.
+
+With --audit (or "audit" in .wt/sandbox.json) the session is recorded under
+audit/ in the state directory: start and end time, image ID, mounts, network
+policy, exit status and git diff --stat of the worktree before and after. Browse
+the records with 'wt sandbox history'.
+
+With --prompt or --prompt-file, Claude runs headless (print mode) with that task.
+Its output is streamed, and the full transcript (stream-json), prompt and exit status
+are saved under logs/<repo>-<hash>/<worktree>/<time>/ in the state directory. wt
+exits with Claude's exit status.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Check podman is available
-		if err := sandbox.CheckPodmanAvailable(); err != nil {
+		prompt, err := readPrompt(cmd)
+		if err != nil {
 			return err
 		}
 
-		cwd, err := os.Getwd()
+		setup, err := setupSandbox(cmd, args)
 		if err != nil {
 			return err
 		}
+		defer setup.close()
 
-		repoRoot, err := worktree.FindRepoRoot(cwd)
+		if prompt != "" {
+			setup.opts.Prompt = prompt
+			return runHeadless(cmd, setup)
+		}
+
+		audit, err := beginAudit(setup, sandbox.AuditInteractive)
 		if err != nil {
-			return fmt.Errorf("not in a git repository")
+			return err
 		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Starting sandbox in %s...\n", setup.opts.WorktreePath)
+		runErr := sandbox.Run(setup.opts)
+		syncGitIsolation(cmd, setup.opts.GitIsolation)
+		if err := finishAudit(audit, exitCodeOf(runErr)); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+		}
+		return runErr
+	},
+}
 
-		mgr := worktree.NewManager(repoRoot)
+// sandboxSetup is a sandbox ready to run: its options and the host-side
+// helpers (egress proxy, git bridge) to shut down afterwards.
+type sandboxSetup struct {
+	opts     *sandbox.Options
+	repoRoot string
+	name     string // Worktree name, or the directory name when run from cwd
+	cleanup  []func()
 
-		var wtPath string
+	claudeHome     string // Isolated Claude home, removed by close unless keepClaudeHome
+	keepClaudeHome bool   // A persistent container still uses claudeHome
+	audit          bool   // Record the session in the audit log
+}
 
-		if len(args) > 0 {
-			name := args[0]
-			if !mgr.Exists(name) {
-				return fmt.Errorf("worktree %q does not exist (use 'claude --worktree %s' to create it)", name, name)
-			}
-			wtPath = mgr.WorktreePath(name)
-		} else {
-			// Use current directory
-			wtPath = cwd
+// close shuts down host-side helpers and removes temporary files.
+func (s *sandboxSetup) close() {
+	for _, fn := range s.cleanup {
+		fn()
+	}
+	if s.claudeHome != "" && !s.keepClaudeHome {
+		os.RemoveAll(s.claudeHome)
+	}
+}
+
+// detectSandboxRuntime detects and checks the runtime selected with --runtime.
+func detectSandboxRuntime() (sandbox.Runtime, error) {
+	runtime, err := sandbox.DetectRuntime(sandboxRuntime)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.Available(); err != nil {
+		return nil, err
+	}
+	return runtime, nil
+}
+
+// setupSandbox resolves the worktree in args (or cwd), builds the image if
+// needed, and prepares git isolation, the egress proxy and git access from
+// flags and .wt/sandbox.json.
+func setupSandbox(cmd *cobra.Command, args []string) (*sandboxSetup, error) {
+	runtime, err := detectSandboxRuntime()
+	if err != nil {
+		return nil, err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	repoRoot, err := worktree.FindRepoRoot(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("not in a git repository")
+	}
+
+	mgr := worktree.NewManager(repoRoot)
+
+	cfg, err := sandbox.LoadConfig(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	network := sandboxNetwork
+	if network == "" {
+		network = cfg.Network
+	}
+	network, err = sandbox.ParseNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+
+	var wtPath, name string
+
+	if len(args) > 0 {
+		name = args[0]
+		if !mgr.Exists(name) {
+			return nil, fmt.Errorf("worktree %q does not exist (use 'wt new %s' or 'claude --worktree %s' to create it)", name, name, name)
 		}
+		wtPath = mgr.WorktreePath(name)
+	} else {
+		// Use current directory
+		wtPath = cwd
+		name = filepath.Base(cwd)
+	}
 
-		// Find the main .git directory
-		mainGitDir := filepath.Join(repoRoot, ".git")
+	// Find the main .git directory
+	mainGitDir := filepath.Join(repoRoot, ".git")
 
-		// Claude credentials directory and config file
-		home, _ := os.UserHomeDir()
-		claudeDir := filepath.Join(home, ".claude")
+	home, _ := os.UserHomeDir()
 
-		// Claude global config file (~/.claude.json) - only mount if exists
-		claudeConfigFile := filepath.Join(home, ".claude.json")
-		if _, err := os.Stat(claudeConfigFile); os.IsNotExist(err) {
-			// Create empty file so Claude Code can write to it
-			if f, err := os.Create(claudeConfigFile); err == nil {
-				f.Close()
-			}
+	// Mise directories (for persisting installed tools, trust state, and cache)
+	miseDataDir := filepath.Join(home, ".local", "share", "mise")
+	miseStateDir := filepath.Join(home, ".local", "state", "mise")
+	miseCacheDir := filepath.Join(home, ".cache", "mise")
+
+	var dc *sandbox.Devcontainer
+	if !sandboxNoDevcontainer {
+		// From the main checkout: the agent can edit the worktree's copy
+		dc, err = sandbox.LoadDevcontainer(repoRoot)
+		if err != nil {
+			return nil, err
 		}
+	}
+	if dc != nil {
+		if err := allowDevcontainerRunArgs(cmd, dc, wtPath); err != nil {
+			return nil, err
+		}
+	}
+
+	imageName, err := ensureImage(cmd, runtime, repoRoot, dc)
+	if err != nil {
+		return nil, err
+	}
 
-		// Mise directories (for persisting installed tools, trust state, and cache)
-		miseDataDir := filepath.Join(home, ".local", "share", "mise")
-		miseStateDir := filepath.Join(home, ".local", "state", "mise")
-		miseCacheDir := filepath.Join(home, ".cache", "mise")
+	opts := &sandbox.Options{
+		WorktreePath:   wtPath,
+		MainGitDir:     mainGitDir,
+		MiseDataDir:    miseDataDir,
+		MiseStateDir:   miseStateDir,
+		MiseCacheDir:   miseCacheDir,
+		ExtraMounts:    sandboxMounts,
+		ContainerImage: imageName,
+		Runtime:        runtime,
+		Network:        network,
+		Limits:         cfg.Limits.Merge(sandboxLimits),
+		RunMiseInstall: !sandboxNoMise,
+		StartClaude:    !sandboxNoClaude,
+	}
+	setup := &sandboxSetup{opts: opts, repoRoot: repoRoot, name: name, audit: sandboxAudit || cfg.Audit}
+
+	ports, err := mgr.Ports(name)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range ports.Env() {
+		setEnv(opts, k, v)
+	}
+	if sandboxPublish || cfg.Publish {
+		if network == sandbox.NetworkNone {
+			return nil, fmt.Errorf("--publish needs network access; use --network=full or allowlist")
+		}
+		// Same port numbers inside and out, reachable from the host only
+		opts.Ports = append(opts.Ports, fmt.Sprintf("127.0.0.1:%s:%s", ports, ports))
+	}
 
-		// Build/check image
-		imageName := sandboxImage
-		if imageName == "" {
-			imageName = "wt-sandbox"
+	if dc != nil {
+		if err := dc.Apply(opts); err != nil {
+			return nil, err
+		}
+		if props := dc.Unsupported(); len(props) > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s: ignoring %s\n", dc.Path, strings.Join(props, ", "))
 		}
+		forwardDevcontainerPorts(cmd, opts, dc, ports, sandboxPublish || cfg.Publish)
+	}
 
-		if !sandbox.ImageExists(imageName) {
-			fmt.Fprintln(cmd.OutOrStdout(), "Building sandbox image (this may take a few minutes)...")
-			containerfile := findContainerfile(repoRoot)
-			if containerfile == "" {
-				return fmt.Errorf("Containerfile not found. Place it at <repo>/Containerfile or ~/.local/share/wt/Containerfile, or specify --image")
+	if !sandboxRoGit {
+		isolation, err := sandbox.NewGitIsolation(wtPath)
+		if err != nil {
+			return nil, fmt.Errorf("isolating git refs: %w", err)
+		}
+		if isolation != nil {
+			// A persistent container removed behind wt's back no longer uses its git state
+			if container := isolation.Container(); container != "" {
+				if _, found, err := sandbox.FindContainer(runtime, repoRoot, name); err == nil && !found {
+					if err := isolation.SetContainer(""); err != nil {
+						return nil, fmt.Errorf("isolating git refs: %w", err)
+					}
+				}
 			}
-			if err := sandbox.BuildImage(containerfile, imageName); err != nil {
-				return fmt.Errorf("building image: %w", err)
+			if err := isolation.Prepare(); err != nil {
+				return nil, fmt.Errorf("isolating git refs: %w", err)
 			}
+			setup.cleanup = append(setup.cleanup, isolation.Unlock)
+			opts.GitIsolation = isolation
 		}
+	}
+
+	if err := setupEnv(cmd, setup, cfg); err != nil {
+		setup.close()
+		return nil, err
+	}
+
+	if err := setupClaudeHome(cmd, setup, cfg, home); err != nil {
+		setup.close()
+		return nil, err
+	}
 
-		opts := &sandbox.Options{
-			WorktreePath:     wtPath,
-			MainGitDir:       mainGitDir,
-			ClaudeDir:        claudeDir,
-			ClaudeConfigFile: claudeConfigFile,
-			MiseDataDir:      miseDataDir,
-			MiseStateDir:     miseStateDir,
-			MiseCacheDir:     miseCacheDir,
-			ExtraMounts:      sandboxMounts,
-			ContainerImage:   imageName,
-			RunMiseInstall:   !sandboxNoMise,
-			StartClaude:      !sandboxNoClaude,
+	var egressNet *sandbox.EgressNetwork
+	if network == sandbox.NetworkAllowlist {
+		allow := append(append(append([]string{}, sandbox.DefaultAllowHosts...), cfg.AllowHosts...), sandboxAllow...)
+		n, stop, err := startEgressProxy(cmd, opts, allow)
+		if err != nil {
+			setup.close()
+			return nil, err
 		}
+		egressNet = n
+		setup.cleanup = append(setup.cleanup, stop)
+	}
 
-		fmt.Fprintf(cmd.OutOrStdout(), "Starting sandbox in %s...\n", wtPath)
-		return sandbox.Run(opts)
-	},
+	stop, err := setupGitAccess(cmd, opts, cfg, egressNet, repoRoot, home)
+	if err != nil {
+		setup.close()
+		return nil, err
+	}
+	if stop != nil {
+		setup.cleanup = append(setup.cleanup, stop)
+	}
+
+	return setup, nil
+}
+
+// readPrompt returns the task from --prompt or --prompt-file, or "" for an interactive run.
+func readPrompt(cmd *cobra.Command) (string, error) {
+	if sandboxPromptF == "" {
+		return sandboxPrompt, nil
+	}
+	var data []byte
+	var err error
+	if sandboxPromptF == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(sandboxPromptF)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading prompt: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("prompt file %s is empty", sandboxPromptF)
+	}
+	return string(data), nil
+}
+
+// runHeadless runs the prepared sandbox with a prompt, logging under the
+// worktree's log directory, and turns a failed run into wt's exit status.
+func runHeadless(cmd *cobra.Command, setup *sandboxSetup) error {
+	logDir, err := sandbox.LogDir(setup.repoRoot, setup.name)
+	if err != nil {
+		return err
+	}
+	runDir, err := sandbox.NewRunDir(logDir, time.Now())
+	if err != nil {
+		return err
+	}
+
+	audit, err := beginAudit(setup, sandbox.AuditHeadless)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Running headless sandbox in %s...\n", setup.opts.WorktreePath)
+	status, err := sandbox.RunHeadless(setup.opts, runDir, cmd.OutOrStdout())
+	syncGitIsolation(cmd, setup.opts.GitIsolation)
+	if audit != nil {
+		audit.LogDir = runDir
+		exitCode := status.ExitCode
+		if err != nil {
+			exitCode = -1
+		}
+		if err := finishAudit(audit, exitCode); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Transcript saved to %s\n", runDir)
+	if status.ExitCode != 0 {
+		return &exitCodeError{code: status.ExitCode, msg: fmt.Sprintf("sandbox run failed with exit status %d", status.ExitCode)}
+	}
+	return nil
+}
+
+// syncGitIsolation copies the sandbox's branch back and reports what changed.
+func syncGitIsolation(cmd *cobra.Command, isolation *sandbox.GitIsolation) {
+	if isolation == nil {
+		return
+	}
+	updated, err := isolation.Sync()
+	for _, u := range updated {
+		fmt.Fprintf(cmd.OutOrStdout(), "Updated branch %s\n", u)
+	}
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+	}
 }
 
 func init() {
-	sandboxCmd.Flags().StringArrayVarP(&sandboxMounts, "mount", "m", nil, "Additional paths to mount")
-	sandboxCmd.Flags().BoolVar(&sandboxNoClaude, "no-claude", false, "Don't start Claude, just get a shell")
-	sandboxCmd.Flags().BoolVar(&sandboxNoMise, "no-mise", false, "Don't run mise install")
-	sandboxCmd.Flags().StringVar(&sandboxImage, "image", "", "Container image to use")
+	addSandboxRunFlags(sandboxCmd.Flags())
+	sandboxCmd.Flags().StringVar(&sandboxPrompt, "prompt", "", "Run Claude headless with this task and save the transcript")
+	sandboxCmd.Flags().StringVar(&sandboxPromptF, "prompt-file", "", "Like --prompt, reading the task from a file ('-' for stdin)")
+	sandboxCmd.MarkFlagsMutuallyExclusive("prompt", "prompt-file")
+	sandboxCmd.MarkFlagsMutuallyExclusive("prompt", "no-claude")
+	sandboxCmd.MarkFlagsMutuallyExclusive("prompt-file", "no-claude")
+	sandboxCmd.PersistentFlags().StringVar(&sandboxRuntime, "runtime", "auto", "Container runtime: podman, docker or auto")
 	rootCmd.AddCommand(sandboxCmd)
 }
 
+// addSandboxRunFlags registers the flags that configure a new sandbox container.
+func addSandboxRunFlags(fs *pflag.FlagSet) {
+	fs.StringArrayVarP(&sandboxMounts, "mount", "m", nil, "Additional paths to mount")
+	fs.BoolVar(&sandboxNoClaude, "no-claude", false, "Don't start Claude, just get a shell")
+	fs.BoolVar(&sandboxNoMise, "no-mise", false, "Don't run mise install")
+	fs.StringVar(&sandboxImage, "image", "", "Container image to use")
+	fs.StringVar(&sandboxNetwork, "network", "", "Network policy: full, none or allowlist (default: from .wt/sandbox.json, else full)")
+	fs.StringArrayVar(&sandboxAllow, "allow-host", nil, "Additional host reachable with --network=allowlist (*.example.com for subdomains)")
+	fs.StringVar(&sandboxLimits.CPUs, "cpus", "", "Number of CPUs the container may use (e.g. 2, 1.5)")
+	fs.StringVar(&sandboxLimits.Memory, "memory", "", "Memory limit (e.g. 4g); the container may not swap beyond it")
+	fs.IntVar(&sandboxLimits.PidsLimit, "pids-limit", 0, "Maximum number of processes in the container")
+	fs.StringVar(&sandboxLimits.DiskQuota, "disk-quota", "", "Size limit of the container's writable layer (e.g. 20g; needs overlay on XFS)")
+	fs.StringVar(&sandboxClaudeHome, "claude-home", "", "Claude configuration: isolated (throwaway copy of settings, the default) or host (mount ~/.claude)")
+	fs.StringVar(&sandboxAPIKeyFrom, "api-key-from", "", "Pass an API key from env:NAME, file:PATH or cmd:COMMAND")
+	fs.StringVar(&sandboxOAuthFrom, "oauth-token-from", "", "Pass an OAuth token (claude setup-token) from env:NAME, file:PATH or cmd:COMMAND")
+	fs.StringArrayVarP(&sandboxEnv, "env", "e", nil, "Set a variable in the container (KEY=VALUE, or KEY for the host's value)")
+	fs.StringArrayVar(&sandboxEnvFiles, "env-file", nil, "Read variables from a KEY=VALUE file (a bare KEY passes the host's value)")
+	fs.BoolVar(&sandboxPublish, "publish", false, "Publish the worktree's port range ($WT_PORT_BASE, see 'wt list') on 127.0.0.1")
+	fs.BoolVar(&sandboxSSHAgent, "ssh-agent", false, "Forward the host's SSH agent (SSH_AUTH_SOCK) into the container")
+	fs.BoolVar(&sandboxGitCreds, "git-credentials", false, "Fetch and push HTTPS remotes with the host's git credentials; pushes are limited to the worktree's branch")
+	fs.BoolVar(&sandboxAudit, "audit", false, "Record the session (image, mounts, network, exit status, changes) for 'wt sandbox history'")
+	fs.BoolVar(&sandboxNoDevcontainer, "no-devcontainer", false, "Ignore .devcontainer/devcontainer.json")
+	fs.BoolVar(&sandboxDevcontainerRunArgs, "devcontainer-run-args", false, "Pass devcontainer.json runArgs that change the network, privileges or mounts, and mounts from outside the worktree")
+	fs.BoolVar(&sandboxRoGit, "readonly-git", false, "Mount the main .git read-only (commits in the sandbox fail)")
+}
+
+// allowDevcontainerRunArgs refuses devcontainer runArgs that would override the
+// sandbox's settings, and mounts of host paths outside the worktree at wtPath,
+// unless --devcontainer-run-args lets them through.
+func allowDevcontainerRunArgs(cmd *cobra.Command, dc *sandbox.Devcontainer, wtPath string) error {
+	mounts, err := dc.UnsafeMounts(wtPath)
+	if err != nil {
+		return err
+	}
+	var unsafe []string
+	if args := dc.UnsafeRunArgs(); len(args) > 0 {
+		unsafe = append(unsafe, "runArgs "+strings.Join(args, " "))
+	}
+	if len(mounts) > 0 {
+		unsafe = append(unsafe, "mounts "+strings.Join(mounts, " "))
+	}
+	if len(unsafe) == 0 {
+		return nil
+	}
+	if !sandboxDevcontainerRunArgs {
+		return fmt.Errorf("%s: %s would change the sandbox's network, privileges or mounts; pass --devcontainer-run-args to use them anyway, or --no-devcontainer", dc.Path, strings.Join(unsafe, " and "))
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s: %s override the sandbox's settings\n", dc.Path, strings.Join(unsafe, " and "))
+	dc.AllowRunArgs = true
+	return nil
+}
+
+// forwardDevcontainerPorts publishes the devcontainer's forwardPorts on the
+// host ports of the worktree's range, in order, so sandboxes of different
+// worktrees don't collide. With published set the whole range already is.
+func forwardDevcontainerPorts(cmd *cobra.Command, opts *sandbox.Options, dc *sandbox.Devcontainer, ports worktree.PortRange, published bool) {
+	forward := dc.ForwardedPorts()
+	switch {
+	case len(forward) == 0:
+		return
+	case opts.Network == sandbox.NetworkNone:
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s: ignoring forwardPorts without network access\n", dc.Path)
+		return
+	case published:
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s: ignoring forwardPorts; --publish publishes ports %s\n", dc.Path, ports)
+		return
+	}
+	for i, port := range forward {
+		if i == ports.Count {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s: only the first %d forwardPorts fit the worktree's ports %s\n", dc.Path, ports.Count, ports)
+			break
+		}
+		opts.Ports = append(opts.Ports, fmt.Sprintf("127.0.0.1:%d:%d", ports.Base+i, port))
+		fmt.Fprintf(cmd.ErrOrStderr(), "Forwarding port %d to 127.0.0.1:%d\n", port, ports.Base+i)
+	}
+}
+
+// startEgressProxy creates the internal network of an allowlist sandbox and
+// starts its proxy. It returns the network and a function that removes both
+// and reports how many requests were denied.
+func startEgressProxy(cmd *cobra.Command, opts *sandbox.Options, allow []string) (*sandbox.EgressNetwork, func(), error) {
+	stateDir, err := sandbox.StateDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, nil, err
+	}
+	logPath := filepath.Join(stateDir, "egress.log")
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	egressNet, err := sandbox.StartEgressNetwork(opts.Runtime)
+	if err != nil {
+		logFile.Close()
+		return nil, nil, fmt.Errorf("network allowlist: %w", err)
+	}
+	proxy, err := sandbox.StartEgressProxy(egressNet.Gateway(sandbox.EgressProxyPort), allow, logFile)
+	if err != nil {
+		egressNet.Close()
+		logFile.Close()
+		return nil, nil, err
+	}
+	opts.Egress = proxy
+
+	return egressNet, func() {
+		proxy.Close()
+		egressNet.Close()
+		logFile.Close()
+		if n := proxy.Denied(); n > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Network allowlist denied %d request(s), see %s\n", n, logPath)
+		}
+	}, nil
+}
+
 // findContainerfile looks for a Containerfile in the repo root or ~/.local/share/wt/.
 func findContainerfile(repoRoot string) string {
 	candidates := []string{
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/niref/wt/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyAll   bool
+	historyJSON  bool
+	historyLimit int
+)
+
+// historyOutput is the --json document for `wt sandbox history`.
+type historyOutput struct {
+	Version  int                   `json:"version"`
+	Sessions []sandbox.AuditRecord `json:"sessions"`
+}
+
+var sandboxHistoryCmd = &cobra.Command{
+	Use:   "history [worktree]",
+	Short: "Show the audit log of sandbox sessions",
+	Long: `List the sandbox sessions recorded with --audit (or "audit" in
+.wt/sandbox.json), newest first: when they ran, how they ended and what they
+changed in the worktree. A persistent sandbox still running when 'wt sandbox stop'
+removed it shows as stopped rather than with an exit code. The log is kept as
+JSON lines in audit/<repo>-<hash>.jsonl in wt's state directory (see
+'wt sandbox --help'); --json prints the full records, including the image ID,
+mounts and network policy.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var paths []string
+		if historyAll {
+			dir, err := sandbox.AuditDir()
+			if err != nil {
+				return err
+			}
+			if paths, err = filepath.Glob(filepath.Join(dir, "*.jsonl")); err != nil {
+				return err
+			}
+		} else {
+			repoRoot, err := currentRepoRoot()
+			if err != nil {
+				return err
+			}
+			path, err := sandbox.AuditFile(repoRoot)
+			if err != nil {
+				return err
+			}
+			paths = []string{path}
+		}
+
+		records, err := sandbox.ReadAudit(paths...)
+		if err != nil {
+			return err
+		}
+		sessions := make([]sandbox.AuditRecord, 0, len(records))
+		for i := len(records) - 1; i >= 0; i-- {
+			if len(args) > 0 && records[i].Worktree != args[0] {
+				continue
+			}
+			sessions = append(sessions, records[i])
+			if historyLimit > 0 && len(sessions) == historyLimit {
+				break
+			}
+		}
+
+		if historyJSON {
+			return writeJSON(cmd.OutOrStdout(), historyOutput{Version: jsonSchemaVersion, Sessions: sessions})
+		}
+		if len(sessions) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No sandbox sessions recorded (run wt sandbox with --audit)")
+			return nil
+		}
+		return writeHistoryTable(cmd.OutOrStdout(), sessions, historyAll)
+	},
+}
+
+func init() {
+	sandboxHistoryCmd.Flags().BoolVar(&historyAll, "all", false, "Show sessions of all repositories")
+	sandboxHistoryCmd.Flags().BoolVar(&historyJSON, "json", false, "Output the full records as JSON")
+	sandboxHistoryCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of sessions to show (0 for all)")
+	sandboxCmd.AddCommand(sandboxHistoryCmd)
+}
+
+// writeHistoryTable renders audited sessions as an aligned table, with the
+// repository of each session if withRepo is set.
+func writeHistoryTable(w io.Writer, sessions []sandbox.AuditRecord, withRepo bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if withRepo {
+		fmt.Fprint(tw, "REPO\t")
+	}
+	fmt.Fprintln(tw, "STARTED\tWORKTREE\tMODE\tDURATION\tEXIT\tNETWORK\tCHANGES")
+	for _, s := range sessions {
+		if withRepo {
+			fmt.Fprintf(tw, "%s\t", filepath.Base(s.Repo))
+		}
+		exit := fmt.Sprint(s.ExitCode)
+		switch {
+		case s.ExitCode == sandbox.ExitStopped:
+			exit = "stopped"
+		case s.ExitCode < 0:
+			exit = "error"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Started.Local().Format("2006-01-02 15:04"), s.Worktree, s.Mode,
+			formatDuration(s.Duration()), exit, s.Network, diffSummary(s.DiffAfter))
+	}
+	return tw.Flush()
+}
+
+// diffSummary returns the summary line of git diff --stat output, e.g.
+// "3 files changed, 10 insertions(+)", or "-" for no changes.
+func diffSummary(stat string) string {
+	stat = strings.TrimSpace(stat)
+	if stat == "" {
+		return "-"
+	}
+	lines := strings.Split(stat, "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// beginAudit starts the audit record of a session if auditing is enabled for
+// setup; it returns nil otherwise.
+func beginAudit(setup *sandboxSetup, mode string) (*sandbox.AuditRecord, error) {
+	if !setup.audit {
+		return nil, nil
+	}
+	record, err := sandbox.BeginAudit(setup.opts, setup.repoRoot, setup.name, mode)
+	if err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	return record, nil
+}
+
+// finishAudit completes and saves an audit record started by beginAudit.
+func finishAudit(record *sandbox.AuditRecord, exitCode int) error {
+	if record == nil {
+		return nil
+	}
+	record.Finish(exitCode)
+	if err := sandbox.AppendAudit(*record); err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	return nil
+}
+
+// exitCodeOf returns the exit status of a container run: 0 on success, the
+// runtime's exit status if it exited non-zero, and -1 if it could not run.
+func exitCodeOf(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/niref/wt/internal/sandbox"
+)
+
+func TestWriteHistoryTable(t *testing.T) {
+	start := time.Date(2026, 3, 1, 14, 30, 0, 0, time.Local)
+	sessions := []sandbox.AuditRecord{
+		{Repo: "/src/app", Worktree: "feature", Mode: sandbox.AuditHeadless, Network: sandbox.NetworkAllowlist, Started: start, Finished: start.Add(95 * time.Second),
+			DiffAfter: " main.go | 10 +++++++---\n 1 file changed, 7 insertions(+), 3 deletions(-)"},
+		{Repo: "/src/app", Worktree: "feature", Mode: sandbox.AuditExec, Network: sandbox.NetworkFull, Started: start.Add(-10 * time.Minute), Finished: start.Add(-9 * time.Minute), ExitCode: 2},
+		{Repo: "/src/app", Worktree: "bugfix", Mode: sandbox.AuditInteractive, Network: sandbox.NetworkFull, Started: start.Add(-time.Hour), Finished: start.Add(-time.Hour), ExitCode: -1},
+		{Repo: "/src/api", Worktree: "spike", Mode: sandbox.AuditPersistent, Network: sandbox.NetworkNone, Started: start.Add(-26 * time.Hour), Finished: start, ExitCode: sandbox.ExitStopped},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeHistoryTable(buf, sessions, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "REPO  STARTED           WORKTREE  MODE         DURATION  EXIT     NETWORK    CHANGES\n" +
+		"app   2026-03-01 14:30  feature   headless     1m35s     0        allowlist  1 file changed, 7 insertions(+), 3 deletions(-)\n" +
+		"app   2026-03-01 14:20  feature   exec         1m0s      2        full       -\n" +
+		"app   2026-03-01 13:30  bugfix    interactive  0s        error    full       -\n" +
+		"api   2026-02-28 12:30  spike     persistent   26h0m0s   stopped  none       -\n"
+	if buf.String() != want {
+		t.Errorf("writeHistoryTable() =\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestSandboxHistory_JSON(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"one", "two", "one"} {
+		r := sandbox.AuditRecord{Repo: "/src/app", Worktree: name, Mode: sandbox.AuditHeadless, Started: start.Add(time.Duration(i) * time.Hour)}
+		if err := sandbox.AppendAudit(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+		historyAll, historyJSON, historyLimit = false, false, 20
+	}()
+	rootCmd.SetArgs([]string{"sandbox", "history", "--all", "--json", "one"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out historyOutput
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if out.Version != jsonSchemaVersion || len(out.Sessions) != 2 {
+		t.Fatalf("output = %+v", out)
+	}
+	// Newest first
+	if !out.Sessions[0].Started.After(out.Sessions[1].Started) || out.Sessions[0].Worktree != "one" {
+		t.Errorf("sessions = %+v", out.Sessions)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/niref/wt/internal/sandbox"
+	"github.com/niref/wt/internal/worktree"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var sandboxRecreate bool
+
+var sandboxStartCmd = &cobra.Command{
+	Use:   "start <name>",
+	Short: "Start a persistent sandbox in the background",
+	Long: `Start a sandbox for a worktree in the background. Unlike 'wt sandbox', the
+container keeps running when the terminal closes and keeps its installed state
+until 'wt sandbox stop'. A stopped sandbox container is started again as it was,
+with the settings it was created with; to change them, pass --recreate to remove
+it (as 'wt sandbox stop' does) and create a new one.
+
+Takes the same flags as 'wt sandbox', except that --network=allowlist and
+--git-credentials are not supported because the egress proxy and git bridge only
+run while wt does.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		rt, err := detectSandboxRuntime()
+		if err != nil {
+			return err
+		}
+		repoRoot, err := currentRepoRoot()
+		if err != nil {
+			return err
+		}
+		existing, found, err := sandbox.FindContainer(rt, repoRoot, name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case found && existing.Running():
+			return fmt.Errorf("sandbox for %s is already running (attach with 'wt sandbox attach %s')", name, name)
+		case found && sandboxRecreate:
+			if err := stopSandbox(cmd, rt, existing); err != nil {
+				return err
+			}
+		case found:
+			// Before any setup: the container already has its mounts, git
+			// isolation and audit record
+			if flags := sandboxSettingFlags(cmd); len(flags) > 0 {
+				return fmt.Errorf("sandbox for %s keeps the settings it was created with; use --recreate to apply %s", name, strings.Join(flags, ", "))
+			}
+			if err := sandbox.Restart(rt, existing.Name); err != nil {
+				return err
+			}
+			printSandboxStarted(cmd, name)
+			return nil
+		}
+
+		setup, err := setupSandbox(cmd, args)
+		if err != nil {
+			return err
+		}
+		defer setup.close()
+
+		opts := setup.opts
+		if opts.Network == sandbox.NetworkAllowlist {
+			return fmt.Errorf("--network=allowlist is not supported for persistent sandboxes; use none or full")
+		}
+		if opts.GitBridge != nil {
+			return fmt.Errorf("--git-credentials is not supported for persistent sandboxes; use --ssh-agent")
+		}
+
+		opts.Name = sandbox.ContainerName(setup.repoRoot, name)
+		opts.Labels = map[string]string{
+			sandbox.LabelRepo:     setup.repoRoot,
+			sandbox.LabelWorktree: name,
+			sandbox.LabelPath:     opts.WorktreePath,
+		}
+		if setup.claudeHome != "" {
+			opts.Labels[sandbox.LabelClaudeHome] = setup.claudeHome
+		}
+		audit, err := beginAudit(setup, sandbox.AuditPersistent)
+		if err != nil {
+			return err
+		}
+		if err := sandbox.Start(opts); err != nil {
+			return err
+		}
+		setup.keepClaudeHome = true
+		if isolation := opts.GitIsolation; isolation != nil {
+			if err := isolation.SetContainer(opts.Name); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+			}
+		}
+		if audit != nil {
+			if err := sandbox.SavePendingAudit(*audit); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: audit log: %v\n", err)
+			}
+		}
+
+		printSandboxStarted(cmd, name)
+		return nil
+	},
+}
+
+// printSandboxStarted tells how to reach a persistent sandbox that was started.
+func printSandboxStarted(cmd *cobra.Command, name string) {
+	fmt.Fprintf(cmd.OutOrStdout(), "Started sandbox for %s\n", name)
+	fmt.Fprintf(cmd.OutOrStdout(), "Attach with 'wt sandbox attach %s' (detach with ctrl-p ctrl-q)\n", name)
+}
+
+// sandboxSettingFlags returns the flags given on the command line that set up a
+// new container, which restarting an existing one would ignore.
+func sandboxSettingFlags(cmd *cobra.Command) []string {
+	var flags []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name != "recreate" && f.Name != "runtime" {
+			flags = append(flags, "--"+f.Name)
+		}
+	})
+	return flags
+}
+
+var sandboxAttachCmd = &cobra.Command{
+	Use:   "attach <name>",
+	Short: "Attach to a persistent sandbox",
+	Long:  `Connect the terminal to the agent running in a persistent sandbox. Detach with ctrl-p ctrl-q.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, c, err := findSandboxContainer(args[0])
+		if err != nil {
+			return err
+		}
+		if !c.Running() {
+			return fmt.Errorf("sandbox for %s is %s (start it with 'wt sandbox start %s')", args[0], c.Status, args[0])
+		}
+		return sandbox.Attach(rt, c.Name)
+	},
+}
+
+var sandboxExecCmd = &cobra.Command{
+	Use:   "exec <name> -- <command>...",
+	Short: "Run a command in a persistent sandbox",
+	Long: `Run a command in a running persistent sandbox. If the sandbox was started with
+--audit, the command is recorded in the audit log as a session of its own.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, c, err := findSandboxContainer(args[0])
+		if err != nil {
+			return err
+		}
+		if !c.Running() {
+			return fmt.Errorf("sandbox for %s is %s (start it with 'wt sandbox start %s')", args[0], c.Status, args[0])
+		}
+		audit, err := sandbox.BeginExecAudit(c.Name, args[1:])
+		if err != nil {
+			return fmt.Errorf("audit log: %w", err)
+		}
+		execErr := sandbox.Exec(rt, c.Name, args[1:], isTerminal(os.Stdin))
+		if err := finishAudit(audit, exitCodeOf(execErr)); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+		}
+		return execErr
+	},
+}
+
+var sandboxStopCmd = &cobra.Command{
+	Use:   "stop <name>...",
+	Short: "Stop and remove persistent sandboxes",
+	Long: `Stop and remove the sandbox containers of worktrees. The worktree's branch is
+copied back from the sandbox's private git refs, as when 'wt sandbox' exits, and
+the session is added to the audit log if it was started with --audit.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var failed int
+		for _, name := range args {
+			rt, c, err := findSandboxContainer(name)
+			if err == nil {
+				err = stopSandbox(cmd, rt, c)
+			}
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", name, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to stop %d sandbox(es)", failed)
+		}
+		return nil
+	},
+}
+
+var sandboxPsCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List persistent sandboxes of this repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := detectSandboxRuntime()
+		if err != nil {
+			return err
+		}
+		repoRoot, err := currentRepoRoot()
+		if err != nil {
+			return err
+		}
+
+		containers, err := sandbox.ListContainers(rt, repoRoot)
+		if err != nil {
+			return err
+		}
+		if len(containers) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No sandboxes found")
+			return nil
+		}
+		return writeSandboxTable(cmd.OutOrStdout(), containers)
+	},
+}
+
+// stopSandbox stops and removes a persistent sandbox container, copies its
+// branch back and completes its audit record.
+func stopSandbox(cmd *cobra.Command, rt sandbox.Runtime, c sandbox.Container) error {
+	exitCode, err := sandbox.Stop(rt, c.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Stopped sandbox for %s\n", c.Worktree)
+	if c.ClaudeHome != "" {
+		if err := removeClaudeHome(c.ClaudeHome); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+		}
+	}
+
+	if isolation, err := sandbox.NewGitIsolation(c.Path); err == nil {
+		syncGitIsolation(cmd, isolation)
+	}
+	// After the sync, so the record sees the sandbox's commits
+	if err := sandbox.FinishPendingAudit(c.Name, exitCode); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: audit log: %v\n", err)
+	}
+	return nil
+}
+
+func init() {
+	addSandboxRunFlags(sandboxStartCmd.Flags())
+	sandboxStartCmd.Flags().BoolVar(&sandboxRecreate, "recreate", false, "Replace a stopped sandbox container instead of starting it again")
+	sandboxCmd.AddCommand(sandboxStartCmd, sandboxAttachCmd, sandboxExecCmd, sandboxStopCmd, sandboxPsCmd)
+}
+
+// currentRepoRoot returns the root of the repository containing the working directory.
+func currentRepoRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	repoRoot, err := worktree.FindRepoRoot(cwd)
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository")
+	}
+	return repoRoot, nil
+}
+
+// findSandboxContainer returns the runtime and persistent container of a worktree.
+func findSandboxContainer(name string) (sandbox.Runtime, sandbox.Container, error) {
+	rt, err := detectSandboxRuntime()
+	if err != nil {
+		return nil, sandbox.Container{}, err
+	}
+	repoRoot, err := currentRepoRoot()
+	if err != nil {
+		return nil, sandbox.Container{}, err
+	}
+	c, found, err := sandbox.FindContainer(rt, repoRoot, name)
+	if err != nil {
+		return nil, sandbox.Container{}, err
+	}
+	if !found {
+		return nil, sandbox.Container{}, fmt.Errorf("no sandbox for %s (start one with 'wt sandbox start %s')", name, name)
+	}
+	return rt, c, nil
+}
+
+// writeSandboxTable renders persistent sandboxes as an aligned table.
+func writeSandboxTable(w io.Writer, containers []sandbox.Container) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKTREE\tSTATUS\tCONTAINER")
+	for _, c := range containers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Worktree, c.Status, c.Name)
+	}
+	return tw.Flush()
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
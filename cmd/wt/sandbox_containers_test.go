@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestSandboxSettingFlags(t *testing.T) {
+	cmd := &cobra.Command{}
+	var network, runtime string
+	var recreate, audit bool
+	cmd.Flags().StringVar(&network, "network", "", "")
+	cmd.Flags().StringVar(&runtime, "runtime", "auto", "")
+	cmd.Flags().BoolVar(&recreate, "recreate", false, "")
+	cmd.Flags().BoolVar(&audit, "audit", false, "")
+
+	if err := cmd.ParseFlags([]string{"--runtime=docker", "--recreate"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sandboxSettingFlags(cmd); len(got) != 0 {
+		t.Errorf("sandboxSettingFlags = %v, want none for --runtime and --recreate", got)
+	}
+
+	if err := cmd.ParseFlags([]string{"--network=none", "--audit"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sandboxSettingFlags(cmd); !slices.Equal(got, []string{"--audit", "--network"}) {
+		t.Errorf("sandboxSettingFlags = %v, want [--audit --network]", got)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/niref/wt/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+// setupClaudeHome configures how the sandboxed Claude gets its configuration
+// and credentials, from flags and .wt/sandbox.json.
+func setupClaudeHome(cmd *cobra.Command, setup *sandboxSetup, cfg sandbox.Config, home string) error {
+	opts := setup.opts
+	mode := sandboxClaudeHome
+	if mode == "" {
+		mode = cfg.ClaudeHome
+	}
+	mode, err := sandbox.ParseClaudeHome(mode)
+	if err != nil {
+		return err
+	}
+
+	var creds sandbox.Credentials
+	if source := firstNonEmpty(sandboxAPIKeyFrom, cfg.APIKeyFrom); source != "" {
+		if creds.APIKey, err = sandbox.ReadSecret(source); err != nil {
+			return fmt.Errorf("API key: %w", err)
+		}
+	}
+	if source := firstNonEmpty(sandboxOAuthFrom, cfg.OAuthTokenFrom); source != "" {
+		if creds.OAuthToken, err = sandbox.ReadSecret(source); err != nil {
+			return fmt.Errorf("OAuth token: %w", err)
+		}
+	}
+
+	stateDir, err := sandbox.StateDir()
+	if err != nil {
+		return err
+	}
+
+	claudeDir := filepath.Join(home, ".claude")
+	claudeConfigFile := filepath.Join(home, ".claude.json")
+	switch mode {
+	case sandbox.ClaudeHomeHost:
+		fmt.Fprintln(cmd.ErrOrStderr(), "Warning: --claude-home=host lets the sandbox change your Claude settings, hooks and login in ~/.claude")
+		opts.ClaudeDir = claudeDir
+		// Mounted only if it exists; wt does not create files in the home directory
+		if _, err := os.Stat(claudeConfigFile); err == nil {
+			opts.ClaudeConfigFile = claudeConfigFile
+		}
+	case sandbox.ClaudeHomeIsolated:
+		parent := filepath.Join(stateDir, "claude-home")
+		if err := os.MkdirAll(parent, 0700); err != nil {
+			return err
+		}
+		dir, err := os.MkdirTemp(parent, setup.name+"-*")
+		if err != nil {
+			return err
+		}
+		setup.claudeHome = dir
+		if err := sandbox.SeedClaudeHome(dir, claudeDir, claudeConfigFile, creds); err != nil {
+			return err
+		}
+		if creds == (sandbox.Credentials{}) {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Note: Claude has no login in the sandbox; pass --api-key-from or --oauth-token-from, or --claude-home=host to use your login")
+		}
+		opts.Mounts = append(opts.Mounts, sandbox.Mount{Source: dir, Target: dir, Mode: "Z"})
+		setEnv(opts, "CLAUDE_CONFIG_DIR", dir)
+		setEnv(opts, "HOME", home)
+	}
+
+	if env := creds.Env(); len(env) > 0 {
+		envFile, err := sandbox.WriteEnvFile(filepath.Join(stateDir, "secrets"), env)
+		if err != nil {
+			return err
+		}
+		// The runtime reads the file when the container is created
+		setup.cleanup = append(setup.cleanup, func() { os.Remove(envFile) })
+		opts.EnvFiles = append(opts.EnvFiles, envFile)
+	}
+	return nil
+}
+
+// removeClaudeHome removes the isolated Claude home of a persistent container.
+// Only directories wt created are removed.
+func removeClaudeHome(dir string) error {
+	stateDir, err := sandbox.StateDir()
+	if err != nil {
+		return err
+	}
+	parent := filepath.Join(stateDir, "claude-home") + string(filepath.Separator)
+	if !strings.HasPrefix(filepath.Clean(dir), parent) {
+		return fmt.Errorf("not removing %s: not an isolated Claude home", dir)
+	}
+	return os.RemoveAll(dir)
+}
+
+// setEnv sets an environment variable in the container.
+func setEnv(opts *sandbox.Options, key, value string) {
+	if opts.Env == nil {
+		opts.Env = make(map[string]string)
+	}
+	opts.Env[key] = value
+}
+
+// firstNonEmpty returns the first argument that is not "".
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// setupGitAccess forwards the SSH agent and starts the git credential bridge
+// when enabled by flags or .wt/sandbox.json. In allowlist mode the bridge is
+// reached through egressNet. It returns a function that stops the bridge, or nil.
+func setupGitAccess(cmd *cobra.Command, opts *sandbox.Options, cfg sandbox.Config, egressNet *sandbox.EgressNetwork, repoRoot, home string) (func(), error) {
+	if sandboxSSHAgent || cfg.SSHAgent {
+		if egressNet != nil {
+			return nil, fmt.Errorf("--ssh-agent: SSH remotes are unreachable with --network=allowlist; use --git-credentials for HTTPS remotes")
+		}
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, fmt.Errorf("--ssh-agent: SSH_AUTH_SOCK is not set; start an SSH agent first")
+		}
+		opts.SSHAgent = sock
+		// Without known hosts, ssh in the container cannot verify the server
+		if knownHosts := filepath.Join(home, ".ssh", "known_hosts"); fileExists(knownHosts) {
+			opts.Mounts = append(opts.Mounts, sandbox.Mount{Source: knownHosts, Target: knownHosts, Mode: "ro"})
+		}
+		fmt.Fprintln(cmd.ErrOrStderr(), "Warning: --ssh-agent lets the sandbox use your SSH keys; pushes over SSH are not limited to the worktree's branch")
+	}
+
+	if !sandboxGitCreds && !cfg.GitCredentials {
+		return nil, nil
+	}
+	if opts.Network == sandbox.NetworkNone {
+		return nil, fmt.Errorf("--git-credentials needs network access; use --network=full or allowlist")
+	}
+	remotes, err := sandbox.RemoteURLs(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(remotes) == 0 {
+		fmt.Fprintln(cmd.ErrOrStderr(), "Warning: --git-credentials: the repository has no HTTPS remotes")
+		return nil, nil
+	}
+	branch := sandbox.CurrentBranch(opts.WorktreePath)
+	if opts.GitIsolation != nil {
+		branch = opts.GitIsolation.Branch
+	}
+
+	var gw sandbox.HostGateway
+	if egressNet != nil {
+		gw = egressNet.Gateway(sandbox.GitBridgePort)
+	} else if gw, err = opts.Runtime.HostGateway(); err != nil {
+		return nil, err
+	}
+	stateDir, err := sandbox.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, err
+	}
+	logPath := filepath.Join(stateDir, "git-bridge.log")
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	bridge, err := sandbox.StartGitBridge(gw, repoRoot, remotes, branch, logFile)
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+	opts.GitBridge = bridge
+
+	return func() {
+		bridge.Close()
+		logFile.Close()
+		if n := bridge.Denied(); n > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Git bridge refused %d push(es), see %s\n", n, logPath)
+		}
+	}, nil
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/niref/wt/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+// setupEnv passes the allowlisted host variables, and the variables from the
+// worktree's .wt/env, --env-file and --env, later ones taking precedence, to
+// the container. The latter may be secrets, so they go through a private env
+// file instead of the command line.
+func setupEnv(cmd *cobra.Command, setup *sandboxSetup, cfg sandbox.Config) error {
+	opts := setup.opts
+	for _, name := range append(append([]string{}, sandbox.DefaultPassEnv...), cfg.PassEnv...) {
+		if _, set := opts.Env[name]; set {
+			continue
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			setEnv(opts, name, value)
+		}
+	}
+
+	env := make(map[string]string)
+	passHost := func(name string) {
+		if value, ok := os.LookupEnv(name); ok {
+			env[name] = value
+		}
+	}
+
+	// The agent can edit the worktree, so its file may not read host variables
+	path := filepath.Join(opts.WorktreePath, sandbox.WorktreeEnvFile)
+	vars, hostOnly, err := sandbox.ReadEnvFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		for k, v := range vars {
+			env[k] = v
+		}
+		if len(hostOnly) > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s: ignoring %s; pass host variables with --env NAME\n", path, strings.Join(hostOnly, ", "))
+		}
+	}
+
+	for _, file := range sandboxEnvFiles {
+		vars, hostOnly, err := sandbox.ReadEnvFile(file)
+		if err != nil {
+			return err
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+		for _, name := range hostOnly {
+			passHost(name)
+		}
+	}
+
+	for _, arg := range sandboxEnv {
+		key, value, hostOnly, err := sandbox.ParseEnv(arg)
+		if err != nil {
+			return err
+		}
+		if hostOnly {
+			passHost(key)
+			continue
+		}
+		env[key] = value
+	}
+
+	if len(env) == 0 {
+		return nil
+	}
+	// Command-line variables would win over the env file
+	for k := range env {
+		delete(opts.Env, k)
+	}
+	stateDir, err := sandbox.StateDir()
+	if err != nil {
+		return err
+	}
+	envFile, err := sandbox.WriteEnvFile(filepath.Join(stateDir, "secrets"), env)
+	if err != nil {
+		return err
+	}
+	setup.cleanup = append(setup.cleanup, func() { os.Remove(envFile) })
+	opts.EnvFiles = append(opts.EnvFiles, envFile)
+	return nil
+}
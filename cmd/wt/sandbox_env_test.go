@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/niref/wt/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+func TestSetupEnv(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmp, "state"))
+	t.Setenv("TERM", "xterm-256color")
+	t.Setenv("WT_TEST_EXTRA", "extra")
+	t.Setenv("WT_TEST_TOKEN", "host-token")
+
+	wtPath := filepath.Join(tmp, "wt")
+	if err := os.MkdirAll(filepath.Join(wtPath, ".wt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wtPath, sandbox.WorktreeEnvFile), []byte("FROM_WORKTREE=1\nOVERRIDDEN=worktree\nWT_TEST_TOKEN\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(tmp, "extra.env")
+	if err := os.WriteFile(envFile, []byte("OVERRIDDEN=file\nWT_TEST_TOKEN\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sandboxEnv = []string{"FROM_FLAG=yes", "WT_TEST_UNSET"}
+	sandboxEnvFiles = []string{envFile}
+	defer func() {
+		sandboxEnv = nil
+		sandboxEnvFiles = nil
+	}()
+
+	setup := &sandboxSetup{opts: &sandbox.Options{WorktreePath: wtPath, Env: map[string]string{"OVERRIDDEN": "devcontainer"}}}
+	defer setup.close()
+	cmd := &cobra.Command{}
+	stderr := new(bytes.Buffer)
+	cmd.SetErr(stderr)
+	if err := setupEnv(cmd, setup, sandbox.Config{PassEnv: []string{"WT_TEST_EXTRA"}}); err != nil {
+		t.Fatalf("setupEnv failed: %v", err)
+	}
+
+	if got := setup.opts.Env["TERM"]; got != "xterm-256color" {
+		t.Errorf("TERM = %q, want it passed from the host", got)
+	}
+	if got := setup.opts.Env["WT_TEST_EXTRA"]; got != "extra" {
+		t.Errorf("WT_TEST_EXTRA = %q, want it passed with pass_env", got)
+	}
+	if _, ok := setup.opts.Env["OVERRIDDEN"]; ok {
+		t.Errorf("OVERRIDDEN is still set on the command line, where it would win over the env file")
+	}
+	if !strings.Contains(stderr.String(), "ignoring WT_TEST_TOKEN") {
+		t.Errorf("stderr = %q, want a warning about the host variable in .wt/env", stderr.String())
+	}
+
+	if len(setup.opts.EnvFiles) != 1 {
+		t.Fatalf("EnvFiles = %v, want one env file", setup.opts.EnvFiles)
+	}
+	data, err := os.ReadFile(setup.opts.EnvFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The token comes from --env-file, which may read host variables
+	want := "FROM_FLAG=yes\nFROM_WORKTREE=1\nOVERRIDDEN=file\nWT_TEST_TOKEN=host-token\n"
+	if string(data) != want {
+		t.Errorf("env file = %q, want %q", data, want)
+	}
+
+	setup.close()
+	if _, err := os.Stat(setup.opts.EnvFiles[0]); !os.IsNotExist(err) {
+		t.Errorf("env file not removed on close: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niref/wt/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+// defaultSandboxImage is the image wt builds when --image is not given.
+const defaultSandboxImage = "wt-sandbox"
+
+var (
+	buildNoCache bool
+	imagesPrune  bool
+)
+
+var sandboxBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build the sandbox image",
+	Long: `Build the sandbox image from the Containerfile, and the repository's layer
+from .wt/Containerfile.extra if there is one, even if they are up to date.
+
+'wt sandbox' rebuilds the images by itself when a Containerfile or the files it
+copies change; use this to pick up newer base images or packages.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := detectSandboxRuntime()
+		if err != nil {
+			return err
+		}
+		repoRoot, err := currentRepoRoot()
+		if err != nil {
+			return err
+		}
+		containerfile := findContainerfile(repoRoot)
+		if containerfile == "" {
+			return fmt.Errorf("Containerfile not found. Place it at <repo>/Containerfile or ~/.local/share/wt/Containerfile")
+		}
+
+		base := sandboxImageName()
+		hash, err := sandbox.ContainerfileHash(containerfile)
+		if err != nil {
+			return fmt.Errorf("hashing %s: %w", containerfile, err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Building %s from %s...\n", base, containerfile)
+		opts := sandbox.BuildOptions{Containerfile: containerfile, Image: base, Hash: hash, NoCache: buildNoCache}
+		if err := sandbox.BuildImage(rt, opts); err != nil {
+			return fmt.Errorf("building image: %w", err)
+		}
+
+		overlay := sandbox.FindOverlay(repoRoot)
+		if overlay == "" {
+			return nil
+		}
+		opts, err = overlayBuildOptions(rt, repoRoot, overlay, base)
+		if err != nil {
+			return err
+		}
+		opts.NoCache = buildNoCache
+		fmt.Fprintf(cmd.OutOrStdout(), "Building %s from %s...\n", opts.Image, overlay)
+		if err := sandbox.BuildImage(rt, opts); err != nil {
+			return fmt.Errorf("building repository image: %w", err)
+		}
+		return nil
+	},
+}
+
+var sandboxImagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "List sandbox images built by wt",
+	Long: `List the sandbox images wt has built, including per-repository layers. An
+image is current if it was built from the Containerfile as it is now. Rebuilds
+leave the previous image behind untagged; remove those with --prune.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := detectSandboxRuntime()
+		if err != nil {
+			return err
+		}
+		repoRoot, err := currentRepoRoot()
+		if err != nil {
+			return err
+		}
+
+		images, err := sandbox.ListImages(rt)
+		if err != nil {
+			return err
+		}
+
+		if imagesPrune {
+			var failed int
+			for _, img := range images {
+				if len(img.Tags) > 0 {
+					continue
+				}
+				if err := sandbox.RemoveImage(rt, img.ID); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", shortID(img.ID), err)
+					failed++
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed image %s\n", shortID(img.ID))
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to remove %d image(s)", failed)
+			}
+			return nil
+		}
+
+		if len(images) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No sandbox images found")
+			return nil
+		}
+		var current []string
+		if containerfile := findContainerfile(repoRoot); containerfile != "" {
+			if hash, err := sandbox.ContainerfileHash(containerfile); err == nil {
+				current = append(current, hash)
+			}
+		}
+		if overlay := sandbox.FindOverlay(repoRoot); overlay != "" {
+			if opts, err := overlayBuildOptions(rt, repoRoot, overlay, sandboxImageName()); err == nil {
+				current = append(current, opts.Hash)
+			}
+		}
+		return writeImageTable(cmd.OutOrStdout(), images, current, time.Now())
+	},
+}
+
+func init() {
+	sandboxBuildCmd.Flags().BoolVar(&buildNoCache, "no-cache", false, "Rebuild every layer")
+	sandboxBuildCmd.Flags().StringVar(&sandboxImage, "image", "", "Image name to build (default: "+defaultSandboxImage+")")
+	sandboxImagesCmd.Flags().BoolVar(&imagesPrune, "prune", false, "Remove images replaced by a rebuild")
+	sandboxCmd.AddCommand(sandboxBuildCmd, sandboxImagesCmd)
+}
+
+// sandboxImageName returns the image selected with --image, or the default.
+func sandboxImageName() string {
+	if sandboxImage != "" {
+		return sandboxImage
+	}
+	return defaultSandboxImage
+}
+
+// ensureImage returns the sandbox image to run, building it when it is missing
+// or, for images wt built, when the Containerfile or the files it copies changed.
+// Images given with --image that wt did not build are used as they are. Without
+// --image, a devcontainer's image or Dockerfile takes the place of the
+// Containerfile. If the repository has a .wt/Containerfile.extra, the returned
+// image is the repository's layer on top of that image, built the same way.
+func ensureImage(cmd *cobra.Command, rt sandbox.Runtime, repoRoot string, dc *sandbox.Devcontainer) (string, error) {
+	image := sandboxImageName()
+	containerfile := findContainerfile(repoRoot)
+	switch {
+	case sandboxImage == "" && dc != nil && dc.Image != "":
+		// Pulled by the runtime when missing
+		image = dc.Image
+	case sandboxImage == "" && dc != nil && dc.Dockerfile() != "":
+		opts, err := dc.BuildOptions(sandbox.RepoImageName("wt-devcontainer", repoRoot))
+		if err != nil {
+			return "", err
+		}
+		if err := buildIfChanged(cmd, rt, opts, false); err != nil {
+			return "", err
+		}
+		image = opts.Image
+	case containerfile == "":
+		if !sandbox.ImageExists(rt, image) {
+			return "", fmt.Errorf("Containerfile not found. Place it at <repo>/Containerfile or ~/.local/share/wt/Containerfile, or specify --image")
+		}
+	default:
+		hash, err := sandbox.ContainerfileHash(containerfile)
+		if err != nil {
+			return "", fmt.Errorf("hashing %s: %w", containerfile, err)
+		}
+		opts := sandbox.BuildOptions{Containerfile: containerfile, Image: image, Hash: hash}
+		if err := buildIfChanged(cmd, rt, opts, sandboxImage != ""); err != nil {
+			return "", err
+		}
+	}
+
+	overlay := sandbox.FindOverlay(repoRoot)
+	if overlay == "" {
+		return image, nil
+	}
+	opts, err := overlayBuildOptions(rt, repoRoot, overlay, image)
+	if err != nil {
+		return "", err
+	}
+	if err := buildIfChanged(cmd, rt, opts, false); err != nil {
+		return "", err
+	}
+	return opts.Image, nil
+}
+
+// overlayBuildOptions returns how to build the repository's layer from overlay on base.
+func overlayBuildOptions(rt sandbox.Runtime, repoRoot, overlay, base string) (sandbox.BuildOptions, error) {
+	hash, err := sandbox.OverlayHash(overlay, sandbox.ImageID(rt, base))
+	if err != nil {
+		return sandbox.BuildOptions{}, fmt.Errorf("hashing %s: %w", overlay, err)
+	}
+	return sandbox.BuildOptions{
+		Containerfile: overlay,
+		Image:         sandbox.RepoImageName(base, repoRoot),
+		Hash:          hash,
+		Base:          base,
+	}, nil
+}
+
+// buildIfChanged builds opts.Image if it is missing or was built from a different
+// hash. With keepForeign, an existing image wt did not build is left alone.
+func buildIfChanged(cmd *cobra.Command, rt sandbox.Runtime, opts sandbox.BuildOptions, keepForeign bool) error {
+	switch built := sandbox.ImageHash(rt, opts.Image); {
+	case !sandbox.ImageExists(rt, opts.Image):
+		fmt.Fprintf(cmd.OutOrStdout(), "Building %s (this may take a few minutes)...\n", opts.Image)
+	case built == opts.Hash:
+		return nil
+	case built == "" && keepForeign:
+		return nil
+	default:
+		fmt.Fprintf(cmd.OutOrStdout(), "%s changed, rebuilding %s...\n", opts.Containerfile, opts.Image)
+	}
+	if err := sandbox.BuildImage(rt, opts); err != nil {
+		return fmt.Errorf("building image: %w", err)
+	}
+	return nil
+}
+
+// writeImageTable renders sandbox images as an aligned table. Images built from
+// one of the current hashes are marked current.
+func writeImageTable(w io.Writer, images []sandbox.Image, current []string, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tID\tCREATED\tSTATUS")
+	for _, img := range images {
+		name := strings.Join(img.Tags, ",")
+		if name == "" {
+			name = "<none>"
+		}
+		created := "-"
+		if !img.Created.IsZero() {
+			created = formatAge(now.Sub(img.Created))
+		}
+		status := "outdated"
+		switch {
+		case len(img.Tags) == 0:
+			status = "replaced"
+		case slices.Contains(current, img.Hash):
+			status = "current"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, shortID(img.ID), created, status)
+	}
+	return tw.Flush()
+}
+
+// shortID abbreviates an image ID the way container runtimes display it.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/niref/wt/internal/sandbox"
+)
+
+func TestWriteImageTable(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	images := []sandbox.Image{
+		{ID: "0123456789abcdef", Tags: []string{"localhost/wt-sandbox:latest"}, Created: now.Add(-2 * time.Hour), Hash: "new"},
+		{ID: "5555555555555555", Tags: []string{"localhost/wt-dev:latest", "localhost/wt-dev:v2"}, Hash: "overlay"},
+		{ID: "fedcba9876543210", Tags: []string{"localhost/wt-other:latest"}, Created: now.Add(-72 * time.Hour), Hash: "old"},
+		// Untagged by a rebuild: replaced even though its hash is current
+		{ID: "aaaaaaaaaaaaaaaa", Created: now.Add(-96 * time.Hour), Hash: "new"},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeImageTable(buf, images, []string{"new", "overlay"}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "IMAGE                                        ID            CREATED  STATUS\n" +
+		"localhost/wt-sandbox:latest                  0123456789ab  2h ago   current\n" +
+		"localhost/wt-dev:latest,localhost/wt-dev:v2  555555555555  -        current\n" +
+		"localhost/wt-other:latest                    fedcba987654  3d ago   outdated\n" +
+		"<none>                                       aaaaaaaaaaaa  4d ago   replaced\n"
+	if buf.String() != want {
+		t.Errorf("writeImageTable() =\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
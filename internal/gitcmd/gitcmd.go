@@ -0,0 +1,55 @@
+// Package gitcmd runs git commands for the packages that shell out to git.
+package gitcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Output runs git in dir with extra environment and returns trimmed stdout.
+// Errors include git's stderr.
+func Output(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SnapshotIndex stages the whole working tree at dir, untracked files included,
+// in a temporary index so the real one is untouched. It returns the environment
+// that points git at the snapshot and a function that removes it.
+func SnapshotIndex(dir string) ([]string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "wt-index-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}
+
+	// Starting from HEAD's tree keeps files that are tracked but ignored
+	if head, err := Output(dir, nil, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		if _, err := Output(dir, env, "read-tree", head); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+	if _, err := Output(dir, env, "add", "-A", "--", "."); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return env, cleanup, nil
+}
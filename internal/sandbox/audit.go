@@ -0,0 +1,287 @@
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/niref/wt/internal/gitcmd"
+)
+
+// Sandbox session modes recorded in the audit log.
+const (
+	AuditInteractive = "interactive"
+	AuditHeadless    = "headless"
+	AuditPersistent  = "persistent"
+	AuditExec        = "exec" // A command run in a persistent container
+)
+
+// AuditRecord describes one sandbox session: what the container could reach
+// and what it changed in the worktree. Records are appended to the repository's
+// audit log as JSON lines when the session ends.
+type AuditRecord struct {
+	Repo       string    `json:"repo"`
+	Worktree   string    `json:"worktree"`
+	Path       string    `json:"path"`
+	Branch     string    `json:"branch,omitempty"`
+	Mode       string    `json:"mode"`                // AuditInteractive, AuditHeadless, AuditPersistent or AuditExec
+	Container  string    `json:"container,omitempty"` // Name of a persistent container
+	Command    []string  `json:"command,omitempty"`   // Command of an exec session
+	Runtime    string    `json:"runtime"`
+	Image      string    `json:"image"`
+	ImageID    string    `json:"image_id,omitempty"` // Local ID of the image the container ran
+	Network    string    `json:"network"`
+	AllowHosts []string  `json:"allow_hosts,omitempty"` // Allowlist of the egress proxy
+	Mounts     []Mount   `json:"mounts"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	ExitCode   int       `json:"exit_code"`         // -1 if the container could not be run, ExitStopped if wt stopped it
+	LogDir     string    `json:"log_dir,omitempty"` // Transcript of a headless run
+
+	HeadBefore string `json:"head_before,omitempty"` // Commit checked out when the session started
+	HeadAfter  string `json:"head_after,omitempty"`  // Commit checked out when it ended
+	DiffBefore string `json:"diff_before,omitempty"` // git diff --stat of uncommitted changes, untracked files included, at the start
+	DiffAfter  string `json:"diff_after,omitempty"`  // git diff --stat against HeadBefore at the end: all the session's changes
+}
+
+// Duration returns how long the session took.
+func (r AuditRecord) Duration() time.Duration {
+	return r.Finished.Sub(r.Started)
+}
+
+// BeginAudit records the configuration of the sandbox described by opts and
+// the state of its worktree before it starts.
+func BeginAudit(opts *Options, repoRoot, worktree, mode string) (*AuditRecord, error) {
+	mounts, err := opts.AllMounts()
+	if err != nil {
+		return nil, err
+	}
+	network := opts.Network
+	if network == "" {
+		network = NetworkFull
+	}
+	r := &AuditRecord{
+		Repo:      repoRoot,
+		Worktree:  worktree,
+		Path:      opts.WorktreePath,
+		Branch:    CurrentBranch(opts.WorktreePath),
+		Mode:      mode,
+		Container: opts.Name,
+		Runtime:   opts.runtime().Name(),
+		Image:     opts.ContainerImage,
+		ImageID:   ImageID(opts.runtime(), opts.ContainerImage),
+		Network:   network,
+		Mounts:    mounts,
+		ExitCode:  -1,
+	}
+	if opts.Egress != nil {
+		r.AllowHosts = opts.Egress.allow
+	}
+	r.begin()
+	return r, nil
+}
+
+// BeginExecAudit starts the record of command run in the persistent container
+// with 'wt sandbox exec', from the record saved when the container was started.
+// It returns nil if the container was started without auditing.
+func BeginExecAudit(container string, command []string) (*AuditRecord, error) {
+	r, err := readPendingAudit(container)
+	if r == nil || err != nil {
+		return nil, err
+	}
+	r.Mode = AuditExec
+	r.Command = command
+	r.ExitCode = -1
+	r.begin()
+	return r, nil
+}
+
+// begin records the start of the session and the state of the worktree.
+func (r *AuditRecord) begin() {
+	r.Started = time.Now()
+	r.HeadBefore, _ = gitcmd.Output(r.Path, nil, "rev-parse", "-q", "--verify", "HEAD")
+	r.DiffBefore = ""
+	if r.HeadBefore != "" {
+		r.DiffBefore = diffStat(r.Path, r.HeadBefore)
+	}
+}
+
+// Finish records the end of the session and the worktree's changes since it
+// started.
+func (r *AuditRecord) Finish(exitCode int) {
+	r.Finished = time.Now()
+	r.ExitCode = exitCode
+	r.HeadAfter, _ = gitcmd.Output(r.Path, nil, "rev-parse", "-q", "--verify", "HEAD")
+	if r.HeadBefore != "" {
+		r.DiffAfter = diffStat(r.Path, r.HeadBefore)
+	}
+}
+
+// diffStat returns git diff --stat of the worktree at dir, untracked files
+// included, against rev, or "" if git fails.
+func diffStat(dir, rev string) string {
+	env, cleanup, err := gitcmd.SnapshotIndex(dir)
+	if err != nil {
+		return ""
+	}
+	defer cleanup()
+	stat, _ := gitcmd.Output(dir, env, "diff", "--cached", "--stat", rev)
+	return stat
+}
+
+// AuditDir returns the directory holding the audit logs, <state>/audit.
+func AuditDir() (string, error) {
+	state, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(state, "audit"), nil
+}
+
+// AuditFile returns the audit log of a repository, <state>/audit/<repo>-<hash>.jsonl.
+func AuditFile(repoRoot string) (string, error) {
+	dir, err := AuditDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, repoKey(repoRoot)+".jsonl"), nil
+}
+
+// AppendAudit appends r to its repository's audit log.
+func AppendAudit(r AuditRecord) error {
+	path, err := AuditFile(r.Repo)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadAudit reads the sessions recorded in the audit logs at paths, oldest
+// first. Missing files are skipped.
+func ReadAudit(paths ...string) ([]AuditRecord, error) {
+	var records []AuditRecord
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var r AuditRecord
+			if err := json.Unmarshal([]byte(line), &r); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			records = append(records, r)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Started.Before(records[j].Started)
+	})
+	return records, nil
+}
+
+// pendingAuditFile is where the audit record of a running persistent
+// container is kept until it is stopped.
+func pendingAuditFile(container string) (string, error) {
+	dir, err := AuditDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pending", container+".json"), nil
+}
+
+// SavePendingAudit keeps the record of a persistent container that was started
+// until FinishPendingAudit. An existing record, from when the container was
+// first started, is kept.
+func SavePendingAudit(r AuditRecord) error {
+	path, err := pendingAuditFile(r.Container)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// readPendingAudit returns the saved record of a persistent container, or nil
+// if there is none.
+func readPendingAudit(container string) (*AuditRecord, error) {
+	path, err := pendingAuditFile(container)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r AuditRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// FinishPendingAudit completes the saved record of a persistent container
+// with its exit code and appends it to the audit log. It does nothing if the
+// container was started without auditing.
+func FinishPendingAudit(container string, exitCode int) error {
+	r, err := readPendingAudit(container)
+	if r == nil || err != nil {
+		return err
+	}
+	r.Finish(exitCode)
+	if err := AppendAudit(*r); err != nil {
+		return err
+	}
+	path, err := pendingAuditFile(container)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
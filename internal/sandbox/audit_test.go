@@ -0,0 +1,181 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudit_BeginFinish(t *testing.T) {
+	repo, wtPath := setupLinkedWorktree(t)
+	if err := os.WriteFile(filepath.Join(wtPath, "dirty.txt"), []byte("before\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, wtPath, nil, "add", "dirty.txt")
+	if err := os.WriteFile(filepath.Join(wtPath, "notes.txt"), []byte("untracked\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{
+		WorktreePath:   wtPath,
+		ContainerImage: "wt-test:latest",
+		Network:        NetworkNone,
+		ClaudeDir:      "/home/user/.claude",
+	}
+	r, err := BeginAudit(opts, repo, "feature", AuditHeadless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Branch != "feature" || r.Mode != AuditHeadless || r.Network != NetworkNone || r.ExitCode != -1 {
+		t.Errorf("record = %+v", r)
+	}
+	if r.HeadBefore == "" || !strings.Contains(r.DiffBefore, "dirty.txt") || !strings.Contains(r.DiffBefore, "notes.txt") {
+		t.Errorf("HeadBefore = %q, DiffBefore = %q", r.HeadBefore, r.DiffBefore)
+	}
+	if len(r.Mounts) < 2 || r.Mounts[0].Source != wtPath {
+		t.Errorf("Mounts = %+v", r.Mounts)
+	}
+
+	// The session commits one file, leaves another uncommitted and creates a
+	// third without adding it
+	if err := os.WriteFile(filepath.Join(wtPath, "new.go"), []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, wtPath, nil, "add", "new.go")
+	git(t, wtPath, nil, "commit", "-m", "add new.go")
+	if err := os.WriteFile(filepath.Join(wtPath, "dirty.txt"), []byte("before\nafter\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wtPath, "untracked.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Finish(3)
+	if r.ExitCode != 3 || r.Finished.Before(r.Started) {
+		t.Errorf("ExitCode = %d, Started = %v, Finished = %v", r.ExitCode, r.Started, r.Finished)
+	}
+	if r.HeadAfter == "" || r.HeadAfter == r.HeadBefore {
+		t.Errorf("HeadAfter = %q, HeadBefore = %q", r.HeadAfter, r.HeadBefore)
+	}
+	for _, want := range []string{"new.go", "dirty.txt", "untracked.txt", "4 files changed"} {
+		if !strings.Contains(r.DiffAfter, want) {
+			t.Errorf("DiffAfter = %q, want %q", r.DiffAfter, want)
+		}
+	}
+}
+
+func TestAudit_AppendRead(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, name := range []string{"second", "first"} {
+		r := AuditRecord{
+			Repo:     "/src/app",
+			Worktree: name,
+			Mode:     AuditInteractive,
+			Mounts:   []Mount{{Source: "/src/app", Target: "/src/app", Mode: "Z"}},
+			Started:  start.Add(time.Duration(1-i) * time.Hour),
+			Finished: start.Add(2 * time.Hour),
+		}
+		if err := AppendAudit(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AppendAudit(AuditRecord{Repo: "/src/other", Worktree: "elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := AuditFile("/src/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("audit log mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	records, err := ReadAudit(path, filepath.Join(filepath.Dir(path), "missing.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Worktree != "first" || records[1].Worktree != "second" {
+		t.Fatalf("records = %+v", records)
+	}
+	if got := records[1].Mounts; len(got) != 1 || got[0].Mode != "Z" {
+		t.Errorf("Mounts = %+v", got)
+	}
+	if got := records[1].Duration(); got != time.Hour {
+		t.Errorf("Duration() = %v, want 1h", got)
+	}
+}
+
+func TestAudit_Pending(t *testing.T) {
+	repo, wtPath := setupLinkedWorktree(t)
+
+	r, err := BeginAudit(&Options{WorktreePath: wtPath, Name: "wt-feature"}, repo, "feature", AuditPersistent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SavePendingAudit(*r); err != nil {
+		t.Fatal(err)
+	}
+	// A restart keeps the record of the first start
+	restarted := *r
+	restarted.Started = r.Started.Add(time.Hour)
+	if err := SavePendingAudit(restarted); err != nil {
+		t.Fatal(err)
+	}
+
+	// Containers started without auditing have no record
+	if execRecord, err := BeginExecAudit("wt-other", []string{"make"}); execRecord != nil || err != nil {
+		t.Errorf("BeginExecAudit() without a record = %+v, %v", execRecord, err)
+	}
+	if err := FinishPendingAudit("wt-other", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	execRecord, err := BeginExecAudit("wt-feature", []string{"make", "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if execRecord.Mode != AuditExec || execRecord.Container != "wt-feature" || !reflect.DeepEqual(execRecord.Command, []string{"make", "test"}) || execRecord.ExitCode != -1 || !execRecord.Started.After(r.Started) {
+		t.Errorf("exec record = %+v", execRecord)
+	}
+	execRecord.Finish(2)
+	if err := AppendAudit(*execRecord); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := FinishPendingAudit("wt-feature", 137); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := AuditFile(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadAudit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Mode != AuditExec {
+		t.Fatalf("records = %+v, want the exec session after the container's", records)
+	}
+	got := records[0]
+	if got.Container != "wt-feature" || got.ExitCode != 137 || !got.Started.Equal(r.Started) {
+		t.Errorf("record = %+v", got)
+	}
+	pending, err := pendingAuditFile("wt-feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pending); !os.IsNotExist(err) {
+		t.Errorf("pending record not removed: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConfigFile is the per-repo sandbox configuration, relative to the repo root.
+const ConfigFile = ".wt/sandbox.json"
+
+// Config holds the user's and per-repo defaults for wt sandbox. Command-line
+// flags override them.
+type Config struct {
+	Network    string   `json:"network,omitempty"`     // Default network policy
+	AllowHosts []string `json:"allow_hosts,omitempty"` // Hosts added to DefaultAllowHosts
+	Publish    bool     `json:"publish,omitempty"`     // Publish the worktree's port range
+	PassEnv    []string `json:"pass_env,omitempty"`    // Host variables added to DefaultPassEnv
+	Audit      bool     `json:"audit,omitempty"`       // Record sessions in the audit log
+	Limits              // Default resource limits
+
+	ClaudeHome     string `json:"claude_home,omitempty"`      // ClaudeHomeHost or ClaudeHomeIsolated
+	APIKeyFrom     string `json:"api_key_from,omitempty"`     // Secret source for the API key, see ReadSecret
+	OAuthTokenFrom string `json:"oauth_token_from,omitempty"` // Secret source for an OAuth token
+
+	SSHAgent       bool `json:"ssh_agent,omitempty"`       // Forward the host's SSH agent
+	GitCredentials bool `json:"git_credentials,omitempty"` // Bridge HTTPS remotes with the host's git credentials
+}
+
+// UserConfigFile is the user's sandbox configuration, relative to ConfigDir.
+// It holds the same settings as ConfigFile and is the only file that may name
+// secret sources.
+const UserConfigFile = "sandbox.json"
+
+// ConfigDir returns the directory for wt configuration, $XDG_CONFIG_HOME/wt or
+// ~/.config/wt.
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "wt"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "wt"), nil
+}
+
+// LoadConfig reads the user's sandbox.json in ConfigDir and then .wt/sandbox.json
+// from the repo root, whose settings win. Missing files are skipped. The repo's
+// file is committed with the code, so it may not set api_key_from or
+// oauth_token_from: those read files and run commands on the host.
+func LoadConfig(repoRoot string) (Config, error) {
+	var cfg Config
+	dir, err := ConfigDir()
+	if err != nil {
+		return cfg, err
+	}
+	if err := readConfig(filepath.Join(dir, UserConfigFile), &cfg, true); err != nil {
+		return cfg, err
+	}
+	if err := readConfig(filepath.Join(repoRoot, ConfigFile), &cfg, false); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// readConfig reads the configuration file at path over cfg, if it exists.
+// Secret sources are refused unless secrets is set.
+func readConfig(path string, cfg *Config, secrets bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := file.Limits.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := ParseClaudeHome(file.ClaudeHome); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if !secrets && (file.APIKeyFrom != "" || file.OAuthTokenFrom != "") {
+		return fmt.Errorf("%s: api_key_from and oauth_token_from may only be set in the user's %s or with flags", path, UserConfigFile)
+	}
+	// Only the settings the file has replace those read before
+	return json.Unmarshal(data, cfg)
+}
@@ -0,0 +1,174 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Labels set on persistent sandbox containers so wt can find them again.
+const (
+	LabelRepo       = "wt.repo"        // Main repository root
+	LabelWorktree   = "wt.worktree"    // Worktree name
+	LabelPath       = "wt.path"        // Worktree path
+	LabelClaudeHome = "wt.claude-home" // Isolated Claude home, removed with the container
+)
+
+// Container is a persistent sandbox container.
+type Container struct {
+	Name       string `json:"name"`
+	Worktree   string `json:"worktree"`
+	Path       string `json:"path"`
+	Status     string `json:"status"`                // Runtime state, e.g. "running" or "exited"
+	ClaudeHome string `json:"claude_home,omitempty"` // Isolated Claude home, if any
+}
+
+// Running reports whether the container is running.
+func (c Container) Running() bool {
+	return c.Status == "running"
+}
+
+// invalidNameChars matches characters not allowed in container names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// ContainerName returns the persistent container name for a worktree:
+// wt-<repo>-<worktree>, with characters runtimes reject replaced by "-".
+func ContainerName(repoRoot, worktree string) string {
+	name := "wt-" + filepath.Base(repoRoot) + "-" + worktree
+	return invalidNameChars.ReplaceAllString(name, "-")
+}
+
+// Start runs a detached sandbox container named opts.Name. The container keeps
+// running when the terminal closes; use Attach to reconnect to it.
+func Start(opts *Options) error {
+	opts.Detach = true
+	args, err := opts.BuildArgs()
+	if err != nil {
+		return err
+	}
+	args = append(args, "bash", "-c", opts.innerCommand())
+	return runQuiet(opts.runtime(), args...)
+}
+
+// Restart starts an existing stopped container again, keeping its filesystem.
+func Restart(rt Runtime, name string) error {
+	return runQuiet(rt, "start", name)
+}
+
+// Attach connects the terminal to a running container's main process.
+// Detach again with ctrl-p ctrl-q.
+func Attach(rt Runtime, name string) error {
+	return runInteractive(rt, "attach", name)
+}
+
+// Exec runs command in a running container, with a TTY if tty is set.
+func Exec(rt Runtime, name string, command []string, tty bool) error {
+	args := []string{"exec", "-i"}
+	if tty {
+		args = append(args, "-t")
+	}
+	args = append(args, name)
+	return runInteractive(rt, append(args, command...)...)
+}
+
+// ExitStopped is the exit code Stop reports for a container that was still
+// running, since its main process did not exit by itself.
+const ExitStopped = -2
+
+// Stop stops and removes a persistent container. It returns the exit code of
+// the container's main process if it had already exited, ExitStopped if it was
+// still running, or -1 if the runtime does not report it.
+func Stop(rt Runtime, name string) (int, error) {
+	// Before stopping, which would make the exit code the signal's
+	exitCode := -1
+	if out, err := exec.Command(rt.Name(), "inspect", "--format", "{{.State.Running}} {{.State.ExitCode}}", name).Output(); err == nil {
+		running, code, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+		if n, err := strconv.Atoi(code); err == nil {
+			exitCode = n
+		}
+		if running == "true" {
+			exitCode = ExitStopped
+		}
+	}
+	if err := runQuiet(rt, "stop", name); err != nil {
+		return -1, err
+	}
+	return exitCode, runQuiet(rt, "rm", name)
+}
+
+// ListContainers returns the persistent sandbox containers of a repository,
+// running or not.
+func ListContainers(rt Runtime, repoRoot string) ([]Container, error) {
+	out, err := exec.Command(rt.Name(), "ps", "-a", "-q", "--filter", "label="+LabelRepo+"="+repoRoot).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s ps: %w", rt.Name(), err)
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	format := fmt.Sprintf(`{{.Name}}{{"\t"}}{{index .Config.Labels %q}}{{"\t"}}{{index .Config.Labels %q}}{{"\t"}}{{.State.Status}}{{"\t"}}{{index .Config.Labels %q}}`,
+		LabelWorktree, LabelPath, LabelClaudeHome)
+	out, err = exec.Command(rt.Name(), append([]string{"inspect", "--format", format}, ids...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s inspect: %w", rt.Name(), err)
+	}
+	return parseContainers(string(out)), nil
+}
+
+// parseContainers parses the tab-separated inspect output of ListContainers.
+func parseContainers(out string) []Container {
+	var containers []Container
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		containers = append(containers, Container{
+			// Docker prefixes names with "/"
+			Name:       strings.TrimPrefix(fields[0], "/"),
+			Worktree:   fields[1],
+			Path:       fields[2],
+			Status:     fields[3],
+			ClaudeHome: labelValue(fields[4]),
+		})
+	}
+	return containers
+}
+
+// FindContainer returns the persistent container of a worktree, if any.
+func FindContainer(rt Runtime, repoRoot, worktree string) (Container, bool, error) {
+	containers, err := ListContainers(rt, repoRoot)
+	if err != nil {
+		return Container{}, false, err
+	}
+	for _, c := range containers {
+		if c.Worktree == worktree {
+			return c, true, nil
+		}
+	}
+	return Container{}, false, nil
+}
+
+// runQuiet runs a runtime command, returning its error output on failure.
+func runQuiet(rt Runtime, args ...string) error {
+	out, err := exec.Command(rt.Name(), args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", rt.Name(), args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// runInteractive runs a runtime command connected to the terminal.
+func runInteractive(rt Runtime, args ...string) error {
+	cmd := exec.Command(rt.Name(), args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
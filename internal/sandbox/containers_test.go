@@ -0,0 +1,64 @@
+package sandbox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContainerName(t *testing.T) {
+	tests := []struct {
+		repoRoot, worktree, want string
+	}{
+		{"/home/user/dev/myrepo", "feature", "wt-myrepo-feature"},
+		{"/home/user/dev/my repo", "fix/bug#1", "wt-my-repo-fix-bug-1"},
+	}
+	for _, tt := range tests {
+		if got := ContainerName(tt.repoRoot, tt.worktree); got != tt.want {
+			t.Errorf("ContainerName(%q, %q) = %q, want %q", tt.repoRoot, tt.worktree, got, tt.want)
+		}
+	}
+}
+
+func TestParseContainers(t *testing.T) {
+	out := "/wt-repo-a\ta\t/repo/.claude/worktrees/a\trunning\t<no value>\n" +
+		"wt-repo-b\tb\t/repo/.claude/worktrees/b\texited\t/state/claude-home/b-1\n" +
+		"\n"
+	want := []Container{
+		{Name: "wt-repo-a", Worktree: "a", Path: "/repo/.claude/worktrees/a", Status: "running"},
+		{Name: "wt-repo-b", Worktree: "b", Path: "/repo/.claude/worktrees/b", Status: "exited", ClaudeHome: "/state/claude-home/b-1"},
+	}
+	got := parseContainers(out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseContainers() = %+v, want %+v", got, want)
+	}
+	if !got[0].Running() || got[1].Running() {
+		t.Error("Running() should only be true for running containers")
+	}
+}
+
+func TestBuildArgsDetached(t *testing.T) {
+	opts := &Options{
+		WorktreePath:   "/tmp/wt",
+		ContainerImage: "wt-sandbox",
+		Name:           "wt-repo-wt",
+		Labels:         map[string]string{LabelWorktree: "wt", LabelRepo: "/repo"},
+		Detach:         true,
+	}
+	args, err := opts.BuildArgs()
+	if err != nil {
+		t.Fatalf("BuildArgs failed: %v", err)
+	}
+	argStr := strings.Join(args, " ")
+	if !strings.HasPrefix(argStr, "run -d -it --name wt-repo-wt --label wt.repo=/repo --label wt.worktree=wt ") {
+		t.Errorf("unexpected detached args: %s", argStr)
+	}
+	if strings.Contains(argStr, "--rm") {
+		t.Errorf("detached sandbox must outlive its main process, got: %s", argStr)
+	}
+
+	opts.Name = ""
+	if _, err := opts.BuildArgs(); err == nil {
+		t.Error("BuildArgs should require a name for detached sandboxes")
+	}
+}
@@ -0,0 +1,173 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Where the sandboxed Claude Code keeps its configuration and login.
+const (
+	ClaudeHomeHost     = "host"     // Mount ~/.claude and ~/.claude.json read-write
+	ClaudeHomeIsolated = "isolated" // Throwaway directory seeded from the host's settings
+)
+
+// ParseClaudeHome validates a Claude home mode; "" means ClaudeHomeIsolated.
+func ParseClaudeHome(name string) (string, error) {
+	switch name {
+	case "":
+		return ClaudeHomeIsolated, nil
+	case ClaudeHomeHost, ClaudeHomeIsolated:
+		return name, nil
+	default:
+		return "", fmt.Errorf("invalid Claude home %q (want host or isolated)", name)
+	}
+}
+
+// Credentials are secrets passed into the sandbox as environment variables.
+type Credentials struct {
+	APIKey     string // ANTHROPIC_API_KEY
+	OAuthToken string // CLAUDE_CODE_OAUTH_TOKEN, e.g. from 'claude setup-token'
+}
+
+// Env returns the environment variables that carry the credentials.
+func (c Credentials) Env() map[string]string {
+	env := make(map[string]string)
+	if c.APIKey != "" {
+		env["ANTHROPIC_API_KEY"] = c.APIKey
+	}
+	if c.OAuthToken != "" {
+		env["CLAUDE_CODE_OAUTH_TOKEN"] = c.OAuthToken
+	}
+	return env
+}
+
+// ReadSecret reads a secret from source: "env:NAME" for an environment
+// variable, "file:PATH" for a file, or "cmd:COMMAND" for the first line a shell
+// command prints, e.g. "cmd:pass show anthropic/api-key".
+func ReadSecret(source string) (string, error) {
+	kind, arg, _ := strings.Cut(source, ":")
+	var secret string
+	switch kind {
+	case "env":
+		secret = os.Getenv(arg)
+	case "file":
+		if rest, ok := strings.CutPrefix(arg, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			arg = filepath.Join(home, rest)
+		}
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return "", fmt.Errorf("reading secret: %w", err)
+		}
+		secret = string(data)
+	case "cmd":
+		cmd := exec.Command("sh", "-c", arg)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("secret command %q: %w", arg, err)
+		}
+		secret, _, _ = strings.Cut(string(out), "\n")
+	default:
+		return "", fmt.Errorf("invalid secret source %q (want env:NAME, file:PATH or cmd:COMMAND)", source)
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("secret from %s is empty", source)
+	}
+	return secret, nil
+}
+
+// WriteEnvFile writes env to a new file in dir that only the user can read, for
+// passing secrets with --env-file instead of on the command line. The caller
+// removes the file.
+func WriteEnvFile(dir string, env map[string]string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "*.env")
+	if err != nil {
+		return "", err
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.ContainsAny(env[k], "\r\n") {
+			f.Close()
+			os.Remove(f.Name())
+			return "", fmt.Errorf("value of %s contains a newline", k)
+		}
+		fmt.Fprintf(f, "%s=%s\n", k, env[k])
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// claudeHomeFiles are copied from ~/.claude into an isolated Claude home.
+// Credentials and history are not.
+var claudeHomeFiles = []string{"settings.json", "CLAUDE.md", "commands", "agents"}
+
+// claudeConfigKeys are kept from ~/.claude.json in an isolated Claude home, so
+// onboarding and the --dangerously-skip-permissions prompt are not repeated.
+var claudeConfigKeys = []string{"hasCompletedOnboarding", "lastOnboardingVersion", "theme", "bypassPermissionsModeAccepted"}
+
+// SeedClaudeHome fills dir, used as CLAUDE_CONFIG_DIR in the container, with the
+// user's settings, instructions, commands and agents from hostClaudeDir and the
+// preferences from hostConfigFile (~/.claude.json). Login credentials are not
+// copied; pass them with Credentials. An API key in creds is pre-approved so
+// Claude Code does not ask about it.
+func SeedClaudeHome(dir, hostClaudeDir, hostConfigFile string, creds Credentials) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for _, name := range claudeHomeFiles {
+		if err := copyTree(filepath.Join(hostClaudeDir, name), filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("seeding Claude home: %w", err)
+		}
+	}
+
+	config := make(map[string]any)
+	var host map[string]any
+	data, err := os.ReadFile(hostConfigFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &host); err != nil {
+			return fmt.Errorf("parsing %s: %w", hostConfigFile, err)
+		}
+	}
+	for _, key := range claudeConfigKeys {
+		if v, ok := host[key]; ok {
+			config[key] = v
+		}
+	}
+	if key := creds.APIKey; key != "" {
+		// Claude Code remembers approved keys by their last 20 characters
+		config["customApiKeyResponses"] = map[string][]string{
+			"approved": {key[max(0, len(key)-20):]},
+			"rejected": {},
+		}
+	}
+	data, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ".claude.json"), append(data, '\n'), 0600)
+}
@@ -0,0 +1,126 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestReadSecret(t *testing.T) {
+	t.Setenv("WT_TEST_SECRET", "from-env\n")
+	file := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"env:WT_TEST_SECRET", "from-env"},
+		{"file:" + file, "from-file"},
+		{"cmd:printf 'from-cmd\\nsecond line\\n'", "from-cmd"},
+	}
+	for _, tt := range tests {
+		got, err := ReadSecret(tt.source)
+		if err != nil || got != tt.want {
+			t.Errorf("ReadSecret(%q) = %q, %v; want %q", tt.source, got, err, tt.want)
+		}
+	}
+
+	for _, source := range []string{"env:WT_TEST_UNSET", "cmd:false", "file:/nonexistent", "vault:key", "plain"} {
+		if _, err := ReadSecret(source); err == nil {
+			t.Errorf("ReadSecret(%q) should fail", source)
+		}
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secrets")
+	path, err := WriteEnvFile(dir, Credentials{APIKey: "sk-key", OAuthToken: "oauth"}.Env())
+	if err != nil {
+		t.Fatalf("WriteEnvFile failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ANTHROPIC_API_KEY=sk-key\nCLAUDE_CODE_OAUTH_TOKEN=oauth\n"; string(data) != want {
+		t.Errorf("env file = %q, want %q", data, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("env file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	if _, err := WriteEnvFile(dir, map[string]string{"KEY": "a\nB=b"}); err == nil {
+		t.Error("WriteEnvFile should reject values with newlines")
+	}
+}
+
+func TestSeedClaudeHome(t *testing.T) {
+	host := t.TempDir()
+	hostClaudeDir := filepath.Join(host, ".claude")
+	for name, content := range map[string]string{
+		"settings.json":          `{"model": "opus"}`,
+		"CLAUDE.md":              "Be brief.",
+		"commands/review.md":     "Review this.",
+		".credentials.json":      `{"token": "secret"}`,
+		"projects/x/history.txt": "private",
+	} {
+		path := filepath.Join(hostClaudeDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hostConfig := filepath.Join(host, ".claude.json")
+	if err := os.WriteFile(hostConfig, []byte(`{"hasCompletedOnboarding": true, "theme": "dark", "oauthAccount": {"email": "a@example.com"}, "projects": {}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "home")
+	if err := SeedClaudeHome(dir, hostClaudeDir, hostConfig, Credentials{APIKey: "sk-ant-REDACTED"}); err != nil {
+		t.Fatalf("SeedClaudeHome failed: %v", err)
+	}
+
+	for _, name := range []string{"settings.json", "CLAUDE.md", "commands/review.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not copied: %v", name, err)
+		}
+	}
+	for _, name := range []string{".credentials.json", "projects"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s should not be copied", name)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".claude.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if want := []string{"customApiKeyResponses", "hasCompletedOnboarding", "theme"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf(".claude.json keys = %v, want %v", keys, want)
+	}
+	if got, want := string(config["customApiKeyResponses"]), `{
+    "approved": [
+      "6789abcdefghijklmnop"
+    ],
+    "rejected": []
+  }`; got != want {
+		t.Errorf("customApiKeyResponses = %s, want %s", got, want)
+	}
+}
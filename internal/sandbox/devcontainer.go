@@ -0,0 +1,403 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DevcontainerFiles are the devcontainer.json locations wt looks at, relative to
+// the repository root, in order.
+var DevcontainerFiles = []string{
+	".devcontainer/devcontainer.json",
+	".devcontainer.json",
+}
+
+// Devcontainer is the subset of a devcontainer.json that wt sandbox uses.
+// See https://containers.dev/implementors/json_reference/.
+type Devcontainer struct {
+	Path              string            `json:"-"` // File it was read from
+	Image             string            `json:"image"`
+	Build             DevcontainerBuild `json:"build"`
+	DockerFile        string            `json:"dockerFile"` // Deprecated spelling of build.dockerfile
+	ContainerEnv      map[string]string `json:"containerEnv"`
+	Mounts            []json.RawMessage `json:"mounts"`            // Strings or mount objects
+	RunArgs           []string          `json:"runArgs"`           // Passed to the container runtime's run
+	PostCreateCommand json.RawMessage   `json:"postCreateCommand"` // String, array or object of commands
+	ForwardPorts      []json.RawMessage `json:"forwardPorts"`      // Port numbers or "host:port"
+
+	// AllowRunArgs lets runArgs and mounts that weaken the sandbox through (see
+	// UnsafeRunArgs and UnsafeMounts)
+	AllowRunArgs bool `json:"-"`
+
+	// Not supported; reported by Unsupported
+	Features          map[string]any  `json:"features"`
+	DockerComposeFile json.RawMessage `json:"dockerComposeFile"`
+}
+
+// DevcontainerBuild is the build section of a devcontainer.json.
+type DevcontainerBuild struct {
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Args       map[string]string `json:"args"`
+}
+
+// LoadDevcontainer reads the devcontainer.json in dir. It returns nil if there
+// is none.
+func LoadDevcontainer(dir string) (*Devcontainer, error) {
+	for _, name := range DevcontainerFiles {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		dc := &Devcontainer{Path: path}
+		if err := json.Unmarshal(stripJSONC(data), dc); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		return dc, nil
+	}
+	return nil, nil
+}
+
+// Dockerfile returns the path of the Dockerfile the devcontainer is built from,
+// or "" if it uses an image. Paths are relative to devcontainer.json.
+func (d *Devcontainer) Dockerfile() string {
+	dockerfile := d.Build.Dockerfile
+	if dockerfile == "" {
+		dockerfile = d.DockerFile
+	}
+	if dockerfile == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(d.Path), dockerfile)
+}
+
+// BuildOptions returns how to build the devcontainer's Dockerfile as image.
+// The hash covers the Dockerfile, the files it copies and the build args.
+func (d *Devcontainer) BuildOptions(image string) (BuildOptions, error) {
+	dockerfile := d.Dockerfile()
+	context := filepath.Join(filepath.Dir(d.Path), d.Build.Context)
+	if d.Build.Context == "" {
+		context = filepath.Dir(dockerfile)
+	}
+
+	args := make([]string, 0, len(d.Build.Args))
+	for k, v := range d.Build.Args {
+		args = append(args, k+"="+v)
+	}
+	sort.Strings(args)
+	hash, err := contextHash(dockerfile, context, strings.Join(args, "\x00"))
+	if err != nil {
+		return BuildOptions{}, fmt.Errorf("hashing %s: %w", dockerfile, err)
+	}
+	return BuildOptions{
+		Containerfile: dockerfile,
+		Image:         image,
+		Hash:          hash,
+		Context:       context,
+		BuildArgs:     d.Build.Args,
+	}, nil
+}
+
+// Unsupported returns the devcontainer.json properties in use that wt ignores.
+func (d *Devcontainer) Unsupported() []string {
+	var props []string
+	if len(d.Features) > 0 {
+		props = append(props, "features")
+	}
+	if len(d.DockerComposeFile) > 0 {
+		props = append(props, "dockerComposeFile")
+	}
+	return props
+}
+
+// unsafeRunFlags are the run flags that change the sandbox's network,
+// privileges, mounts or security options. Run arguments come last, so any of
+// these would override what wt sets up.
+var unsafeRunFlags = []string{
+	"--network", "--net", "--publish", "-p", "--publish-all", "-P",
+	"--privileged", "--cap-add", "--device", "--user", "-u", "--userns", "--group-add",
+	"--pid", "--ipc", "--uts", "--cgroupns", "--sysctl", "--security-opt",
+	"--volume", "-v", "--mount", "--volumes-from",
+}
+
+// UnsafeRunArgs returns the runArgs that set one of unsafeRunFlags.
+func (d *Devcontainer) UnsafeRunArgs() []string {
+	var unsafe []string
+	for _, arg := range d.RunArgs {
+		flag, _, _ := strings.Cut(arg, "=")
+		for _, f := range unsafeRunFlags {
+			// Short flags may have their value attached, e.g. -v/src:/dst
+			if flag == f || len(f) == 2 && strings.HasPrefix(flag, f) {
+				unsafe = append(unsafe, arg)
+				break
+			}
+		}
+	}
+	return unsafe
+}
+
+// UnsafeMounts returns the bind mounts, as "source:target", whose source is
+// outside the worktree at wtPath. Named volumes are not host paths.
+func (d *Devcontainer) UnsafeMounts(wtPath string) ([]string, error) {
+	mounts, err := d.mounts(wtPath)
+	if err != nil {
+		return nil, err
+	}
+	return unsafeMounts(mounts, wtPath), nil
+}
+
+// unsafeMounts returns the mounts of UnsafeMounts.
+func unsafeMounts(mounts []Mount, wtPath string) []string {
+	var unsafe []string
+	for _, m := range mounts {
+		if strings.Contains(m.Source, "/") && !withinDir(m.Source, wtPath) {
+			unsafe = append(unsafe, m.Source+":"+m.Target)
+		}
+	}
+	return unsafe
+}
+
+// withinDir reports whether path, after following symlinks, is dir or below it.
+func withinDir(path, dir string) bool {
+	resolve := func(p string) string {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return real
+		}
+		return filepath.Clean(p)
+	}
+	rel, err := filepath.Rel(resolve(dir), resolve(path))
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// ForwardedPorts returns the container ports in forwardPorts. Ports of other
+// containers ("service:port") are left out.
+func (d *Devcontainer) ForwardedPorts() []int {
+	var ports []int
+	for _, raw := range d.ForwardPorts {
+		var port int
+		if err := json.Unmarshal(raw, &port); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// Apply adds the devcontainer's environment, mounts, run arguments and
+// post-create command to opts; forwarded ports are left to the caller (see
+// ForwardedPorts). Variables such as ${localWorkspaceFolder} and
+// ${localEnv:NAME} are expanded, with the worktree as the workspace folder.
+// Apply fails on UnsafeRunArgs and UnsafeMounts unless AllowRunArgs is set.
+func (d *Devcontainer) Apply(opts *Options) error {
+	if unsafe := d.UnsafeRunArgs(); len(unsafe) > 0 && !d.AllowRunArgs {
+		return fmt.Errorf("%s: runArgs %s would change the sandbox's network, privileges or mounts", d.Path, strings.Join(unsafe, " "))
+	}
+	mounts, err := d.mounts(opts.WorktreePath)
+	if err != nil {
+		return err
+	}
+	if unsafe := unsafeMounts(mounts, opts.WorktreePath); len(unsafe) > 0 && !d.AllowRunArgs {
+		return fmt.Errorf("%s: mounts %s are outside the worktree", d.Path, strings.Join(unsafe, " "))
+	}
+	expand := func(s string) string { return expandDevcontainerVars(s, opts.WorktreePath) }
+
+	for k, v := range d.ContainerEnv {
+		if opts.Env == nil {
+			opts.Env = make(map[string]string)
+		}
+		opts.Env[k] = expand(v)
+	}
+
+	opts.Mounts = append(opts.Mounts, mounts...)
+
+	for _, arg := range d.RunArgs {
+		opts.RunArgs = append(opts.RunArgs, expand(arg))
+	}
+
+	command, err := devcontainerCommand(d.PostCreateCommand)
+	if err != nil {
+		return fmt.Errorf("%s: postCreateCommand: %w", d.Path, err)
+	}
+	opts.PostCreateCommand = expand(command)
+	return nil
+}
+
+// mounts returns the devcontainer's mounts, with variables expanded for the
+// worktree at wtPath.
+func (d *Devcontainer) mounts(wtPath string) ([]Mount, error) {
+	var mounts []Mount
+	for _, raw := range d.Mounts {
+		m, err := parseDevcontainerMount(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Path, err)
+		}
+		m.Source = expandDevcontainerVars(m.Source, wtPath)
+		m.Target = expandDevcontainerVars(m.Target, wtPath)
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// parseDevcontainerMount parses a mount given as a --mount style string
+// ("source=...,target=...,type=bind") or as an object with the same keys.
+// Host paths are not relabeled for SELinux, since that would change the labels
+// of host files the repository picked; on SELinux hosts they may be unreadable
+// in the container.
+func parseDevcontainerMount(raw json.RawMessage) (Mount, error) {
+	fields := make(map[string]string)
+	var spec string
+	if err := json.Unmarshal(raw, &spec); err == nil {
+		for _, part := range strings.Split(spec, ",") {
+			k, v, _ := strings.Cut(part, "=")
+			fields[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	} else {
+		var obj map[string]any
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return Mount{}, fmt.Errorf("invalid mount %s", raw)
+		}
+		for k, v := range obj {
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+
+	m := Mount{Source: fields["source"] + fields["src"], Target: fields["target"] + fields["dst"] + fields["destination"]}
+	if m.Source == "" || m.Target == "" {
+		return Mount{}, fmt.Errorf("mount %s needs a source and a target", raw)
+	}
+	if readonly, ok := fields["readonly"]; ok && readonly != "false" || fields["ro"] == "true" {
+		m.Mode = "ro"
+	}
+	return m, nil
+}
+
+// devcontainerCommand turns a lifecycle command into a shell command. A string
+// is run by the shell, an array is one command without a shell, and the
+// commands of an object run one after another.
+func devcontainerCommand(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var argv []string
+	if err := json.Unmarshal(raw, &argv); err == nil {
+		quoted := make([]string, len(argv))
+		for i, arg := range argv {
+			quoted[i] = shellQuote(arg)
+		}
+		return strings.Join(quoted, " "), nil
+	}
+	var commands map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &commands); err != nil {
+		return "", fmt.Errorf("want a string, array or object")
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		command, err := devcontainerCommand(commands[name])
+		if err != nil {
+			return "", err
+		}
+		if command != "" {
+			parts = append(parts, "("+command+")")
+		}
+	}
+	return strings.Join(parts, " && "), nil
+}
+
+// devcontainerVar matches ${name} and ${name:arg} variables.
+var devcontainerVar = regexp.MustCompile(`\$\{([^}:]+)(?::([^}]*))?\}`)
+
+// expandDevcontainerVars replaces the devcontainer.json variables wt can resolve.
+// Local and container workspace folders are both the worktree, which is mounted
+// at the same path. Unknown variables are left as they are.
+func expandDevcontainerVars(s, wtPath string) string {
+	return devcontainerVar.ReplaceAllStringFunc(s, func(match string) string {
+		sub := devcontainerVar.FindStringSubmatch(match)
+		switch sub[1] {
+		case "localWorkspaceFolder", "containerWorkspaceFolder":
+			return wtPath
+		case "localWorkspaceFolderBasename", "containerWorkspaceFolderBasename":
+			return filepath.Base(wtPath)
+		case "localEnv", "env":
+			name, def, _ := strings.Cut(sub[2], ":")
+			if v, ok := os.LookupEnv(name); ok {
+				return v
+			}
+			return def
+		}
+		return match
+	})
+}
+
+// stripJSONC removes the comments and trailing commas devcontainer.json allows.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end, len(data)-1)
+			out = append(out, data[i:end+1]...)
+			i = end
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == '}' || c == ']':
+			// Drop a trailing comma before the closing bracket
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// shellQuote quotes s for a POSIX shell if needed.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
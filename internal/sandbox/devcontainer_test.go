@@ -0,0 +1,206 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeDevcontainer(t *testing.T, wtPath, content string) {
+	t.Helper()
+	path := filepath.Join(wtPath, ".devcontainer", "devcontainer.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDevcontainer(t *testing.T) {
+	wtPath := t.TempDir()
+	if dc, err := LoadDevcontainer(wtPath); dc != nil || err != nil {
+		t.Fatalf("LoadDevcontainer without a file = %v, %v; want nil, nil", dc, err)
+	}
+
+	writeDevcontainer(t, wtPath, `{
+  // Comments and trailing commas are allowed
+  "image": "mcr.microsoft.com/devcontainers/go:1", /* inline */
+  "containerEnv": {"DOCS_URL": "https://example.com/a//b", "HOST_USER": "${localEnv:WT_TEST_USER:nobody}",},
+  "mounts": [
+    "source=${localWorkspaceFolder}/../cache,target=/cache,type=bind",
+    {"source": "gomod", "target": "/go/pkg/mod", "type": "volume"},
+    "source=/etc/ssl,target=/etc/ssl,type=bind,readonly",
+  ],
+  "runArgs": ["--cap-add=SYS_PTRACE"],
+  "forwardPorts": [3000, "db:5432"],
+  "postCreateCommand": ["go", "mod", "download"],
+  "features": {"ghcr.io/devcontainers/features/node:1": {}},
+}`)
+	t.Setenv("WT_TEST_USER", "alice")
+
+	dc, err := LoadDevcontainer(wtPath)
+	if err != nil {
+		t.Fatalf("LoadDevcontainer failed: %v", err)
+	}
+	if dc.Image != "mcr.microsoft.com/devcontainers/go:1" || dc.Dockerfile() != "" {
+		t.Errorf("image = %q, dockerfile = %q", dc.Image, dc.Dockerfile())
+	}
+	if got := dc.Unsupported(); !reflect.DeepEqual(got, []string{"features"}) {
+		t.Errorf("Unsupported = %v, want [features]", got)
+	}
+
+	if got := dc.UnsafeRunArgs(); !reflect.DeepEqual(got, []string{"--cap-add=SYS_PTRACE"}) {
+		t.Errorf("UnsafeRunArgs = %v", got)
+	}
+	wantUnsafe := []string{wtPath + "/../cache:/cache", "/etc/ssl:/etc/ssl"}
+	if got, err := dc.UnsafeMounts(wtPath); err != nil || !reflect.DeepEqual(got, wantUnsafe) {
+		t.Errorf("UnsafeMounts = %v, %v; want %v", got, err, wantUnsafe)
+	}
+	opts := &Options{WorktreePath: wtPath}
+	if err := dc.Apply(opts); err == nil {
+		t.Fatal("Apply with --cap-add in runArgs: expected error")
+	}
+	dc.AllowRunArgs = true
+	if err := dc.Apply(opts); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	wantEnv := map[string]string{"DOCS_URL": "https://example.com/a//b", "HOST_USER": "alice"}
+	if !reflect.DeepEqual(opts.Env, wantEnv) {
+		t.Errorf("Env = %v, want %v", opts.Env, wantEnv)
+	}
+	wantMounts := []Mount{
+		{Source: wtPath + "/../cache", Target: "/cache"},
+		{Source: "gomod", Target: "/go/pkg/mod"},
+		{Source: "/etc/ssl", Target: "/etc/ssl", Mode: "ro"},
+	}
+	if !reflect.DeepEqual(opts.Mounts, wantMounts) {
+		t.Errorf("Mounts = %+v, want %+v", opts.Mounts, wantMounts)
+	}
+	if !reflect.DeepEqual(opts.RunArgs, []string{"--cap-add=SYS_PTRACE"}) {
+		t.Errorf("RunArgs = %v", opts.RunArgs)
+	}
+	if len(opts.Ports) != 0 || !reflect.DeepEqual(dc.ForwardedPorts(), []int{3000}) {
+		t.Errorf("Ports = %v, ForwardedPorts = %v", opts.Ports, dc.ForwardedPorts())
+	}
+	if opts.PostCreateCommand != "go mod download" {
+		t.Errorf("PostCreateCommand = %q", opts.PostCreateCommand)
+	}
+}
+
+func TestDevcontainerUnsafeRunArgs(t *testing.T) {
+	dc := &Devcontainer{RunArgs: []string{
+		"--init", "--shm-size=1g", "--add-host=db:10.0.0.5", "-e", "DEBUG=1",
+		"--network=host", "--privileged", "--security-opt", "seccomp=unconfined",
+		"-v/etc:/host-etc", "--mount=type=bind,src=/,dst=/host", "-u", "0",
+	}}
+	want := []string{"--network=host", "--privileged", "--security-opt", "-v/etc:/host-etc", "--mount=type=bind,src=/,dst=/host", "-u"}
+	if got := dc.UnsafeRunArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnsafeRunArgs = %v, want %v", got, want)
+	}
+}
+
+func TestDevcontainerUnsafeMounts(t *testing.T) {
+	wtPath := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(wtPath, "link")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WT_TEST_HOME", outside)
+
+	dc := &Devcontainer{Mounts: []json.RawMessage{
+		json.RawMessage(`"source=${localWorkspaceFolder}/node_modules,target=/cache,type=bind"`),
+		json.RawMessage(`"source=${localWorkspaceFolder},target=/src,type=bind"`),
+		json.RawMessage(`{"source": "gomod", "target": "/go/pkg/mod", "type": "volume"}`),
+		json.RawMessage(`"source=${localEnv:WT_TEST_HOME},target=/home"`),
+		json.RawMessage(`"source=/var/run/docker.sock,target=/var/run/docker.sock"`),
+		json.RawMessage(`"source=${localWorkspaceFolder}/link,target=/escape"`),
+	}}
+	want := []string{outside + ":/home", "/var/run/docker.sock:/var/run/docker.sock", wtPath + "/link:/escape"}
+	got, err := dc.UnsafeMounts(wtPath)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("UnsafeMounts = %v, %v; want %v", got, err, want)
+	}
+
+	opts := &Options{WorktreePath: wtPath}
+	if err := dc.Apply(opts); err == nil || !strings.Contains(err.Error(), "outside the worktree") {
+		t.Errorf("Apply = %v, want the mounts refused", err)
+	}
+	if len(opts.Mounts) != 0 {
+		t.Errorf("Mounts = %+v after a refused Apply", opts.Mounts)
+	}
+}
+
+func TestDevcontainerBuild(t *testing.T) {
+	wtPath := t.TempDir()
+	writeDevcontainer(t, wtPath, `{"build": {"dockerfile": "Dockerfile", "context": "..", "args": {"GO_VERSION": "1.23"}}}`)
+	if err := os.WriteFile(filepath.Join(wtPath, ".devcontainer", "Dockerfile"), []byte("FROM golang\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err := LoadDevcontainer(wtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := dc.BuildOptions("wt-devcontainer-x")
+	if err != nil {
+		t.Fatalf("BuildOptions failed: %v", err)
+	}
+	if opts.Containerfile != filepath.Join(wtPath, ".devcontainer", "Dockerfile") || opts.Context != wtPath {
+		t.Errorf("Containerfile = %q, Context = %q", opts.Containerfile, opts.Context)
+	}
+	if opts.Hash == "" || opts.BuildArgs["GO_VERSION"] != "1.23" {
+		t.Errorf("BuildOptions = %+v", opts)
+	}
+}
+
+func TestDevcontainerCommand(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`"npm ci && npm run build"`, "npm ci && npm run build"},
+		{`["echo", "it's here"]`, `echo 'it'\''s here'`},
+		{`{"server": "npm ci", "tools": ["go", "install", "./..."]}`, "(npm ci) && (go install ./...)"},
+		{`null`, ""},
+	}
+	for _, tt := range tests {
+		got, err := devcontainerCommand([]byte(tt.json))
+		if err != nil || got != tt.want {
+			t.Errorf("devcontainerCommand(%s) = %q, %v; want %q", tt.json, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuildArgsDevcontainerOptions(t *testing.T) {
+	opts := &Options{
+		WorktreePath:      "/tmp/test-worktree",
+		ContainerImage:    "wt-sandbox",
+		Env:               map[string]string{"B": "2", "A": "1"},
+		Ports:             []string{"127.0.0.1:3000:3000"},
+		Mounts:            []Mount{{Source: "gomod", Target: "/go/pkg/mod"}},
+		RunArgs:           []string{"--cap-add=SYS_PTRACE"},
+		PostCreateCommand: "make deps",
+		RunMiseInstall:    true,
+		StartClaude:       true,
+	}
+
+	args, err := opts.BuildArgs()
+	if err != nil {
+		t.Fatalf("BuildArgs failed: %v", err)
+	}
+	argStr := strings.Join(args, " ")
+	for _, want := range []string{"-e A=1 -e B=2", "-p 127.0.0.1:3000:3000", "-v gomod:/go/pkg/mod ", "--cap-add=SYS_PTRACE -w /tmp/test-worktree wt-sandbox"} {
+		if !strings.Contains(argStr, want) {
+			t.Errorf("missing %q in: %s", want, argStr)
+		}
+	}
+
+	want := "if [ ! -e /var/tmp/wt-post-create-done ]; then (make deps) && touch /var/tmp/wt-post-create-done; fi && mise install && claude --dangerously-skip-permissions"
+	if got := opts.innerCommand(); got != want {
+		t.Errorf("innerCommand = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Ports the relay of an EgressNetwork forwards to host services.
+const (
+	EgressProxyPort = 3128
+	GitBridgePort   = 3129
+)
+
+// relayPorts are the ports every relay forwards, whether or not the host
+// service behind one is running.
+var relayPorts = []int{EgressProxyPort, GitBridgePort}
+
+// relayImage runs the relay; socat is all it needs.
+const relayImage = "wt-egress-relay:1"
+
+const relayContainerfile = `FROM docker.io/library/alpine:3.20
+RUN apk add --no-cache socat
+`
+
+// relaySocketDir is where the relay sees the host's socket directory.
+const relaySocketDir = "/run/wt"
+
+// EgressNetwork confines an allowlist sandbox to the host services wt runs for
+// it. The sandbox joins an internal network, which has no route off the host,
+// whose only other member is a relay container. The relay forwards each of
+// relayPorts to a unix socket in a private host directory, where the egress
+// proxy and git bridge listen (see Gateway). Nothing else is reachable: not the
+// internet, other containers, or services on the host.
+type EgressNetwork struct {
+	Name string // Network and relay container name
+	IP   string // Relay address on the network
+	dir  string // Host directory of the sockets
+	rt   Runtime
+}
+
+// StartEgressNetwork creates a network and relay for one sandbox, building the
+// relay image first if needed. Close removes both.
+func StartEgressNetwork(rt Runtime) (*EgressNetwork, error) {
+	if !ImageExists(rt, relayImage) {
+		if err := buildRelayImage(rt); err != nil {
+			return nil, fmt.Errorf("building egress relay image: %w", err)
+		}
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "wt-egress-")
+	if err != nil {
+		return nil, err
+	}
+	n := &EgressNetwork{Name: "wt-egress-" + hex.EncodeToString(suffix), dir: dir, rt: rt}
+
+	args := append([]string{"network", "create", "--internal"}, rt.InternalNetworkArgs()...)
+	if err := runQuiet(rt, append(args, n.Name)...); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	var relays []string
+	for _, port := range relayPorts {
+		relays = append(relays, fmt.Sprintf("socat TCP-LISTEN:%d,fork,reuseaddr UNIX-CONNECT:%s/%d.sock &", port, relaySocketDir, port))
+	}
+	args = []string{"run", "-d", "--rm", "--name", n.Name, "--network", n.Name, "--read-only", "--cap-drop=ALL"}
+	// As the host user, so the relay can open the host's private sockets
+	args = append(args, rt.UserArgs()...)
+	args = append(args, volumeArgs(rt, Mount{Source: dir, Target: relaySocketDir, Mode: "Z"})...)
+	args = append(args, relayImage, "sh", "-c", strings.Join(relays, " ")+" wait")
+	if err := runQuiet(rt, args...); err != nil {
+		n.Close()
+		return nil, err
+	}
+
+	out, err := exec.Command(rt.Name(), "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", n.Name).Output()
+	if fields := strings.Fields(string(out)); err == nil && len(fields) > 0 {
+		n.IP = fields[0]
+	} else {
+		n.Close()
+		return nil, fmt.Errorf("finding the egress relay's address: %v", err)
+	}
+	return n, nil
+}
+
+// Gateway returns how the sandbox reaches a host service behind the relay's
+// port, one of EgressProxyPort and GitBridgePort.
+func (n *EgressNetwork) Gateway(port int) HostGateway {
+	return HostGateway{
+		Host:   n.IP,
+		Port:   port,
+		Socket: filepath.Join(n.dir, strconv.Itoa(port)+".sock"),
+		Args:   []string{"--network=" + n.Name},
+	}
+}
+
+// Close removes the relay, the network and the socket directory.
+func (n *EgressNetwork) Close() error {
+	runQuiet(n.rt, "rm", "-f", n.Name)
+	err := runQuiet(n.rt, "network", "rm", n.Name)
+	os.RemoveAll(n.dir)
+	return err
+}
+
+// buildRelayImage builds relayImage from relayContainerfile.
+func buildRelayImage(rt Runtime) error {
+	dir, err := os.MkdirTemp("", "wt-relay-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	containerfile := filepath.Join(dir, "Containerfile")
+	if err := os.WriteFile(containerfile, []byte(relayContainerfile), 0644); err != nil {
+		return err
+	}
+	return BuildImage(rt, BuildOptions{Containerfile: containerfile, Image: relayImage})
+}
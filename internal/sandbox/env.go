@@ -0,0 +1,68 @@
+package sandbox
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// WorktreeEnvFile holds environment variables for a worktree's sandboxes,
+// relative to the worktree.
+const WorktreeEnvFile = ".wt/env"
+
+// DefaultPassEnv are the host variables passed to the container when set, so
+// the terminal UI renders with the host's colors, locale and time zone.
+var DefaultPassEnv = []string{"TERM", "COLORTERM", "LANG", "LC_ALL", "LC_CTYPE", "TZ"}
+
+// envName matches a valid environment variable name.
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseEnv parses a KEY=VALUE assignment. A bare KEY (hostOnly) means the
+// host's value.
+func ParseEnv(s string) (key, value string, hostOnly bool, err error) {
+	key, value, found := strings.Cut(s, "=")
+	if !envName.MatchString(key) {
+		return "", "", false, fmt.Errorf("invalid environment variable name %q", key)
+	}
+	return key, value, !found, nil
+}
+
+// ReadEnvFile reads environment variables from a dotenv-style file: KEY=VALUE
+// lines, optionally prefixed with "export", with blank lines and # comments
+// ignored. Quotes around a value are removed. Bare names are returned in
+// hostOnly, in file order, for the caller to resolve from the host.
+func ReadEnvFile(path string) (env map[string]string, hostOnly []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	env = make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, bare, err := ParseEnv(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if bare {
+			hostOnly = append(hostOnly, key)
+			continue
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return env, hostOnly, nil
+}
@@ -0,0 +1,76 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	tests := []struct {
+		in       string
+		key      string
+		value    string
+		hostOnly bool
+		wantErr  bool
+	}{
+		{in: "FOO=bar", key: "FOO", value: "bar"},
+		{in: "FOO=a=b", key: "FOO", value: "a=b"},
+		{in: "FOO=", key: "FOO", value: ""},
+		{in: "GH_TOKEN", key: "GH_TOKEN", hostOnly: true},
+		{in: "1FOO=bar", wantErr: true},
+		{in: "=bar", wantErr: true},
+		{in: "FOO BAR=baz", wantErr: true},
+	}
+	for _, tt := range tests {
+		key, value, hostOnly, err := ParseEnv(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseEnv(%q) should fail", tt.in)
+			}
+			continue
+		}
+		if err != nil || key != tt.key || value != tt.value || hostOnly != tt.hostOnly {
+			t.Errorf("ParseEnv(%q) = %q, %q, %v, %v; want %q, %q, %v", tt.in, key, value, hostOnly, err, tt.key, tt.value, tt.hostOnly)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env")
+	content := `# Database
+DATABASE_URL=postgres://localhost/dev
+export NODE_ENV="development"
+GREETING='hello world'
+
+GH_TOKEN
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env, hostOnly, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("ReadEnvFile failed: %v", err)
+	}
+	want := map[string]string{
+		"DATABASE_URL": "postgres://localhost/dev",
+		"NODE_ENV":     "development",
+		"GREETING":     "hello world",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+	if !reflect.DeepEqual(hostOnly, []string{"GH_TOKEN"}) {
+		t.Errorf("hostOnly = %v, want [GH_TOKEN]", hostOnly)
+	}
+
+	if err := os.WriteFile(path, []byte("OK=1\nnot valid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadEnvFile(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("ReadEnvFile error = %v, want an error on line 2", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"errors"
+	"os"
+)
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("locked")
+
+// tryLock does not lock where flock is unavailable.
+func tryLock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,435 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/niref/wt/internal/gitcmd"
+)
+
+// GitBridge lets the container fetch from and push to the repository's HTTPS
+// remotes with the host's git credentials, without the credentials entering the
+// container. The container's git is pointed at the bridge with url.insteadOf;
+// the bridge asks the host's credential helpers ('git credential fill') and
+// forwards the request to the remote with them.
+//
+// Only git's smart HTTP requests for the remotes themselves are forwarded, and
+// only with the random token the bridge puts in the insteadOf URLs, so other
+// repositories on the same hosts and other clients on the network get nothing.
+//
+// Pushes may only update the worktree's own branch: the bridge reads the ref
+// updates at the start of each push and refuses the whole push if any other ref
+// would change, or the branch would be deleted. Refused pushes are logged.
+type GitBridge struct {
+	URL     string   // Bridge URL as seen from inside the container
+	Host    string   // Host name of the bridge as seen from inside the container
+	runArgs []string // Run flags that let the container reach the bridge
+
+	remotes  []bridgeRemote
+	token    string // First path element of every request
+	branch   string // Branch pushes may update; "" refuses all pushes
+	scheme   string // Scheme of the remotes, "https" except in tests
+	fill     func(host, path string) (user, password string, err error)
+	log      io.Writer
+	logMu    sync.Mutex
+	denied   atomic.Int64
+	listener net.Listener
+	server   *http.Server
+}
+
+// bridgeRemote is a remote repository the bridge serves.
+type bridgeRemote struct {
+	url  string // As configured, e.g. https://github.com/org/repo.git
+	host string // host[:port]
+	path string // Repository path, without leading or trailing slashes
+}
+
+// newBridgeRemote parses an HTTPS remote URL.
+func newBridgeRemote(remote string) (bridgeRemote, error) {
+	u, err := url.Parse(remote)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return bridgeRemote{}, fmt.Errorf("not an HTTPS remote: %s", remote)
+	}
+	return bridgeRemote{url: remote, host: u.Host, path: strings.Trim(u.Path, "/")}, nil
+}
+
+// StartGitBridge listens where gw says and serves until Close. It serves the
+// HTTPS remote URLs in remotes, asking for credentials in repoDir, and lets
+// pushes update only branch. Refused pushes are written to log, one line each.
+func StartGitBridge(gw HostGateway, repoDir string, remotes []string, branch string, log io.Writer) (*GitBridge, error) {
+	var served []bridgeRemote
+	for _, remote := range remotes {
+		r, err := newBridgeRemote(remote)
+		if err != nil {
+			return nil, err
+		}
+		served = append(served, r)
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	l, addr, err := gw.listen()
+	if err != nil {
+		return nil, fmt.Errorf("starting git bridge: %w", err)
+	}
+	b := &GitBridge{
+		URL:      "http://" + addr,
+		Host:     gw.Host,
+		runArgs:  gw.Args,
+		remotes:  served,
+		token:    hex.EncodeToString(token),
+		branch:   branch,
+		scheme:   "https",
+		fill:     func(host, path string) (string, string, error) { return fillCredential(repoDir, host, path) },
+		log:      log,
+		listener: l,
+	}
+	b.server = &http.Server{Handler: b, ReadHeaderTimeout: 30 * time.Second}
+	go b.server.Serve(l)
+	return b, nil
+}
+
+// Addr returns the address the bridge listens on.
+func (b *GitBridge) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Denied returns the number of pushes refused so far.
+func (b *GitBridge) Denied() int {
+	return int(b.denied.Load())
+}
+
+// Close stops the bridge.
+func (b *GitBridge) Close() error {
+	return b.server.Close()
+}
+
+// Env returns the environment variables that make git in the container use the
+// bridge for the served remotes.
+func (b *GitBridge) Env() map[string]string {
+	env := map[string]string{"GIT_CONFIG_COUNT": strconv.Itoa(len(b.remotes))}
+	for i, r := range b.remotes {
+		env[fmt.Sprintf("GIT_CONFIG_KEY_%d", i)] = fmt.Sprintf("url.%s/%s/%s/%s.insteadOf", b.URL, b.token, r.host, r.path)
+		env[fmt.Sprintf("GIT_CONFIG_VALUE_%d", i)] = r.url
+	}
+	return env
+}
+
+// RemoteURLs returns the HTTPS remote URLs (fetch and push) configured in the
+// repository at dir, for StartGitBridge.
+func RemoteURLs(dir string) ([]string, error) {
+	out, err := gitcmd.Output(dir, nil, "config", "--get-regexp", `^remote\..*\.(push)?url$`)
+	if err != nil {
+		// git config exits 1 when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var remotes []string
+	for _, line := range strings.Split(out, "\n") {
+		_, value, _ := strings.Cut(line, " ")
+		value = strings.TrimSpace(value)
+		if _, err := newBridgeRemote(value); err != nil || seen[value] {
+			continue
+		}
+		seen[value] = true
+		remotes = append(remotes, value)
+	}
+	sort.Strings(remotes)
+	return remotes, nil
+}
+
+// gitEndpoints are the smart HTTP requests the bridge forwards: the method
+// and the path below the repository.
+var gitEndpoints = []struct{ method, suffix string }{
+	{http.MethodGet, "/info/refs"},
+	{http.MethodPost, "/git-upload-pack"},
+	{http.MethodPost, "/git-receive-pack"},
+}
+
+// route returns the remote and the path below it that a request for
+// /<token>/<host>/<repo>/<endpoint> is for. ok is false for anything else,
+// including requests without the bridge's token.
+func (b *GitBridge) route(r *http.Request) (remote bridgeRemote, endpoint string, ok bool) {
+	token, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) != 1 {
+		return bridgeRemote{}, "", false
+	}
+	for _, e := range gitEndpoints {
+		repo, found := strings.CutSuffix(rest, e.suffix)
+		if !found || r.Method != e.method {
+			continue
+		}
+		if e.suffix == "/info/refs" {
+			if service := r.URL.Query().Get("service"); service != "git-upload-pack" && service != "git-receive-pack" {
+				return bridgeRemote{}, "", false
+			}
+		}
+		host, path, _ := strings.Cut(repo, "/")
+		path = strings.Trim(path, "/")
+		for _, remote := range b.remotes {
+			if strings.EqualFold(remote.host, host) && remote.path == path {
+				return remote, e.suffix, true
+			}
+		}
+		return bridgeRemote{}, "", false
+	}
+	return bridgeRemote{}, "", false
+}
+
+// ServeHTTP forwards /<token>/<host>/<repo>/<endpoint> to
+// https://<host>/<repo>/<endpoint> for the served remotes.
+func (b *GitBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	remote, endpoint, ok := b.route(r)
+	if !ok {
+		http.Error(w, "wt sandbox: not a git request for a remote of this repository", http.StatusForbidden)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if endpoint == "/git-receive-pack" {
+		updates, rest, err := readRefUpdates(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if refused := b.refused(updates.refs); len(refused) > 0 {
+			b.deny(w, r, remote, updates, refused)
+			return
+		}
+		body = rest
+	}
+
+	upstream := &url.URL{Scheme: b.scheme, Host: remote.host, Path: "/" + remote.path + endpoint, RawQuery: r.URL.RawQuery}
+	out, err := http.NewRequestWithContext(r.Context(), r.Method, upstream.String(), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out.ContentLength = r.ContentLength
+	for _, k := range []string{"Accept", "Accept-Encoding", "Content-Encoding", "Content-Type", "Git-Protocol", "User-Agent"} {
+		if v := r.Header.Get(k); v != "" {
+			out.Header.Set(k, v)
+		}
+	}
+	if user, password, err := b.fill(remote.host, remote.path); err == nil {
+		out.SetBasicAuth(user, password)
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// refused returns the refs in updates the sandbox may not push: anything but
+// the worktree's branch, and deleting that branch.
+func (b *GitBridge) refused(updates []refUpdate) []string {
+	var refused []string
+	for _, u := range updates {
+		if b.branch == "" || u.ref != "refs/heads/"+b.branch || isZeroID(u.new) {
+			refused = append(refused, u.ref)
+		}
+	}
+	return refused
+}
+
+// deny refuses a push with a status report git shows per ref, after reading
+// the rest of the request so the client sees the response.
+func (b *GitBridge) deny(w http.ResponseWriter, r *http.Request, remote bridgeRemote, updates refUpdates, refused []string) {
+	b.denied.Add(1)
+	if b.log != nil {
+		b.logMu.Lock()
+		fmt.Fprintf(b.log, "%s denied push to %s: %s\n", time.Now().Format(time.RFC3339), remote.url, strings.Join(refused, " "))
+		b.logMu.Unlock()
+	}
+	io.Copy(io.Discard, r.Body)
+
+	// Rejections read the same in both versions of the report
+	if !updates.has("report-status") && !updates.has("report-status-v2") {
+		http.Error(w, "wt sandbox: push refused", http.StatusForbidden)
+		return
+	}
+	reason := "wt sandbox: only " + b.branch + " may be pushed"
+	if b.branch == "" {
+		reason = "wt sandbox: pushing from a detached HEAD is not allowed"
+	}
+	var report bytes.Buffer
+	writePktLine(&report, "unpack ok\n")
+	for _, u := range updates.refs {
+		if b.branch != "" && u.ref == "refs/heads/"+b.branch && isZeroID(u.new) {
+			writePktLine(&report, fmt.Sprintf("ng %s wt sandbox: deleting %s is not allowed\n", u.ref, b.branch))
+			continue
+		}
+		writePktLine(&report, fmt.Sprintf("ng %s %s\n", u.ref, reason))
+	}
+	report.WriteString("0000")
+
+	w.Header().Set("Content-Type", "application/x-git-receive-pack-result")
+	w.Header().Set("Cache-Control", "no-cache")
+	if updates.has("side-band-64k") || updates.has("side-band") {
+		// The report travels in band 1
+		var banded bytes.Buffer
+		for report.Len() > 0 {
+			chunk := report.Next(900)
+			writePktLine(&banded, "\x01"+string(chunk))
+		}
+		banded.WriteString("0000")
+		report = banded
+	}
+	w.Write(report.Bytes())
+}
+
+// refUpdate is one ref a push changes.
+type refUpdate struct {
+	old, new, ref string
+}
+
+// refUpdates are the commands at the start of a receive-pack request.
+type refUpdates struct {
+	refs         []refUpdate
+	capabilities []string
+}
+
+// has reports whether the client asked for a capability.
+func (u refUpdates) has(capability string) bool {
+	for _, c := range u.capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// readRefUpdates parses the ref update commands of a receive-pack request. It
+// returns them with a reader for the complete, unchanged request body.
+func readRefUpdates(r *http.Request) (refUpdates, io.Reader, error) {
+	var consumed bytes.Buffer
+	tee := io.TeeReader(r.Body, &consumed)
+	var body io.Reader = tee
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(tee)
+		if err != nil {
+			return refUpdates{}, nil, err
+		}
+		body = gz
+	}
+
+	var updates refUpdates
+	br := bufio.NewReader(body)
+	for {
+		line, flush, err := readPktLine(br)
+		if err != nil {
+			return refUpdates{}, nil, fmt.Errorf("reading push commands: %w", err)
+		}
+		if flush {
+			break
+		}
+		line, caps, hasCaps := strings.Cut(strings.TrimSuffix(line, "\n"), "\x00")
+		if hasCaps {
+			updates.capabilities = strings.Fields(caps)
+		}
+		if strings.HasPrefix(line, "shallow ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			// e.g. a push certificate, which the bridge cannot check
+			return refUpdates{}, nil, fmt.Errorf("unsupported push command %q", line)
+		}
+		updates.refs = append(updates.refs, refUpdate{old: fields[0], new: fields[1], ref: fields[2]})
+	}
+	return updates, io.MultiReader(&consumed, r.Body), nil
+}
+
+// readPktLine reads one git pkt-line. flush is set for a flush packet (0000).
+func readPktLine(r *bufio.Reader) (line string, flush bool, err error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return "", false, err
+	}
+	var n [2]byte
+	if _, err := hex.Decode(n[:], size[:]); err != nil {
+		return "", false, fmt.Errorf("invalid pkt-line length %q", size)
+	}
+	length := int(n[0])<<8 | int(n[1])
+	if length == 0 {
+		return "", true, nil
+	}
+	if length < 4 {
+		return "", false, fmt.Errorf("invalid pkt-line length %d", length)
+	}
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", false, err
+	}
+	return string(data), false, nil
+}
+
+// writePktLine writes s as a git pkt-line.
+func writePktLine(w *bytes.Buffer, s string) {
+	fmt.Fprintf(w, "%04x%s", len(s)+4, s)
+}
+
+// isZeroID reports whether id is the all-zero object ID of a deleted ref.
+func isZeroID(id string) bool {
+	return strings.Trim(id, "0") == ""
+}
+
+// fillCredential asks the credential helpers configured for the repository at
+// dir for a username and password, without prompting.
+func fillCredential(dir, host, path string) (string, string, error) {
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\npath=%s\n\n", host, path))
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git credential fill: %w", err)
+	}
+	var user, password string
+	for _, line := range strings.Split(string(out), "\n") {
+		k, v, _ := strings.Cut(line, "=")
+		switch k {
+		case "username":
+			user = v
+		case "password":
+			password = v
+		}
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("no credentials for %s", host)
+	}
+	return user, password, nil
+}
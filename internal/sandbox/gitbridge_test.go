@@ -0,0 +1,295 @@
+package sandbox
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoteURLs(t *testing.T) {
+	repo := filepath.Join(t.TempDir(), "repo")
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+
+	remotes, err := RemoteURLs(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remotes) != 0 {
+		t.Errorf("RemoteURLs() without remotes = %v, want none", remotes)
+	}
+
+	git(t, repo, nil, "remote", "add", "origin", "https://github.com/org/repo.git")
+	git(t, repo, nil, "remote", "add", "fork", "git@github.com:me/repo.git")
+	git(t, repo, nil, "remote", "add", "mirror", "https://github.com/org/mirror.git")
+	git(t, repo, nil, "config", "remote.mirror.pushurl", "https://git.example.com:8443/repo.git")
+
+	git(t, repo, nil, "remote", "add", "upstream", "https://github.com/org/repo.git")
+
+	remotes, err = RemoteURLs(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://git.example.com:8443/repo.git", "https://github.com/org/mirror.git", "https://github.com/org/repo.git"}
+	if !reflect.DeepEqual(remotes, want) {
+		t.Errorf("RemoteURLs() = %v, want %v", remotes, want)
+	}
+}
+
+func TestGitBridgeEnv(t *testing.T) {
+	b := &GitBridge{URL: "http://10.0.2.2:4242", token: "abc123", remotes: []bridgeRemote{
+		{url: "https://git.example.com:8443/repo.git", host: "git.example.com:8443", path: "repo.git"},
+		{url: "https://github.com/org/repo", host: "github.com", path: "org/repo"},
+	}}
+	want := map[string]string{
+		"GIT_CONFIG_COUNT":   "2",
+		"GIT_CONFIG_KEY_0":   "url.http://10.0.2.2:4242/abc123/git.example.com:8443/repo.git.insteadOf",
+		"GIT_CONFIG_VALUE_0": "https://git.example.com:8443/repo.git",
+		"GIT_CONFIG_KEY_1":   "url.http://10.0.2.2:4242/abc123/github.com/org/repo.insteadOf",
+		"GIT_CONFIG_VALUE_1": "https://github.com/org/repo",
+	}
+	if got := b.Env(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Env() = %v, want %v", got, want)
+	}
+}
+
+// startTestBridge starts a bridge for upstream that lets pushes update branch
+// and hands out fixed credentials.
+func startTestBridge(t *testing.T, upstream *httptest.Server, branch string, log io.Writer) (*GitBridge, string) {
+	t.Helper()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := "https://" + u.Host + "/org/repo.git"
+	b, err := StartGitBridge(HostGateway{ListenAddr: "127.0.0.1", Host: "127.0.0.1"}, t.TempDir(), []string{remote}, branch, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	b.scheme = "http"
+	b.fill = func(host, path string) (string, string, error) {
+		if path != "org/repo.git" {
+			t.Errorf("credentials requested for path %q, want org/repo.git", path)
+		}
+		return "me", "secret-token", nil
+	}
+	return b, "http://" + b.Addr() + "/" + b.token + "/" + u.Host + "/org/repo.git"
+}
+
+// pushRequest returns a receive-pack request body updating refs from old to new.
+func pushRequest(capabilities string, updates ...[3]string) []byte {
+	var buf bytes.Buffer
+	for i, u := range updates {
+		line := u[0] + " " + u[1] + " " + u[2]
+		if i == 0 {
+			line += "\x00" + capabilities
+		}
+		writePktLine(&buf, line+"\n")
+	}
+	buf.WriteString("0000PACK-DATA")
+	return buf.Bytes()
+}
+
+const (
+	oldID  = "1111111111111111111111111111111111111111"
+	newID  = "2222222222222222222222222222222222222222"
+	zeroID = "0000000000000000000000000000000000000000"
+)
+
+func TestGitBridge_Fetch(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "me" || password != "secret-token" {
+			t.Errorf("upstream got credentials %q/%q", user, password)
+		}
+		if r.URL.Path != "/org/repo.git/info/refs" || r.URL.RawQuery != "service=git-upload-pack" {
+			t.Errorf("upstream got %s", r.URL)
+		}
+		io.WriteString(w, "refs")
+	}))
+	defer upstream.Close()
+	_, repoURL := startTestBridge(t, upstream, "feature", nil)
+
+	resp, err := http.Get(repoURL + "/info/refs?service=git-upload-pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "refs" {
+		t.Errorf("body = %q, want refs", body)
+	}
+}
+
+func TestGitBridge_Refuses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the remote")
+	}))
+	defer upstream.Close()
+	b, repoURL := startTestBridge(t, upstream, "feature", nil)
+	u, _ := url.Parse(upstream.URL)
+	bridge := "http://" + b.Addr() + "/"
+
+	tests := []struct {
+		name, method, url string
+	}{
+		{"other host", http.MethodGet, bridge + b.token + "/example.com/org/repo.git/info/refs?service=git-upload-pack"},
+		{"other repository", http.MethodGet, bridge + b.token + "/" + u.Host + "/org/other.git/info/refs?service=git-upload-pack"},
+		{"without token", http.MethodGet, bridge + u.Host + "/org/repo.git/info/refs?service=git-upload-pack"},
+		{"wrong token", http.MethodGet, bridge + "0123456789abcdef/" + u.Host + "/org/repo.git/info/refs?service=git-upload-pack"},
+		{"dumb HTTP refs", http.MethodGet, repoURL + "/info/refs"},
+		{"other service", http.MethodGet, repoURL + "/info/refs?service=git-upload-archive"},
+		{"object", http.MethodGet, repoURL + "/objects/info/packs"},
+		{"GET upload-pack", http.MethodGet, repoURL + "/git-upload-pack"},
+		{"POST refs", http.MethodPost, repoURL + "/info/refs?service=git-upload-pack"},
+		{"repository root", http.MethodGet, repoURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("status = %d, want 403", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestGitBridge_Push(t *testing.T) {
+	var received []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		io.WriteString(w, "pushed")
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name       string
+		updates    [][3]string
+		wantDenied bool
+		wantReason string
+	}{
+		{"own branch", [][3]string{{oldID, newID, "refs/heads/feature"}}, false, ""},
+		{"new own branch", [][3]string{{zeroID, newID, "refs/heads/feature"}}, false, ""},
+		{"other branch", [][3]string{{oldID, newID, "refs/heads/main"}}, true, "only feature may be pushed"},
+		{"tag", [][3]string{{zeroID, newID, "refs/tags/v1"}}, true, "only feature may be pushed"},
+		{"delete own branch", [][3]string{{oldID, zeroID, "refs/heads/feature"}}, true, "deleting feature is not allowed"},
+		{"own and other branch", [][3]string{{oldID, newID, "refs/heads/feature"}, {oldID, newID, "refs/heads/main"}}, true, "only feature may be pushed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			log := new(bytes.Buffer)
+			b, repoURL := startTestBridge(t, upstream, "feature", log)
+
+			req := pushRequest("report-status side-band-64k", tt.updates...)
+			resp, err := http.Post(repoURL+"/git-receive-pack", "application/x-git-receive-pack-request", bytes.NewReader(req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if !tt.wantDenied {
+				if !bytes.Equal(received, req) {
+					t.Errorf("remote received %q, want the unchanged request %q", received, req)
+				}
+				if b.Denied() != 0 {
+					t.Errorf("Denied() = %d, want 0", b.Denied())
+				}
+				return
+			}
+			if received != nil {
+				t.Errorf("refused push reached the remote")
+			}
+			if b.Denied() != 1 {
+				t.Errorf("Denied() = %d, want 1", b.Denied())
+			}
+			// Band 1 carries the status report
+			for _, u := range tt.updates {
+				if !strings.Contains(string(body), "ng "+u[2]+" wt sandbox: ") {
+					t.Errorf("response %q does not reject %s", body, u[2])
+				}
+			}
+			if !strings.Contains(string(body), "\x01") || !strings.Contains(string(body), "unpack ok") || !strings.Contains(string(body), tt.wantReason) {
+				t.Errorf("response %q is not a side-band status report", body)
+			}
+			if !strings.Contains(log.String(), "denied push") {
+				t.Errorf("log = %q, want the denied push", log.String())
+			}
+		})
+	}
+}
+
+func TestGitBridge_PushDetached(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("push reached the remote")
+	}))
+	defer upstream.Close()
+	_, repoURL := startTestBridge(t, upstream, "", nil)
+
+	req := pushRequest("report-status", [3]string{oldID, newID, "refs/heads/feature"})
+	resp, err := http.Post(repoURL+"/git-receive-pack", "application/x-git-receive-pack-request", bytes.NewReader(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "ng refs/heads/feature wt sandbox: pushing from a detached HEAD") {
+		t.Errorf("response = %q, want the push rejected", body)
+	}
+}
+
+func TestBuildArgsGitAccess(t *testing.T) {
+	gateway := "--network=wt-egress-0123"
+	bridge := &GitBridge{URL: "http://10.89.0.2:3129", Host: "10.89.0.2", runArgs: []string{gateway}, token: "abc123"}
+	bridge.remotes = []bridgeRemote{{url: "https://github.com/org/repo.git", host: "github.com", path: "org/repo.git"}}
+	opts := &Options{
+		WorktreePath:   "/tmp/wt",
+		ContainerImage: "wt-sandbox",
+		Network:        NetworkAllowlist,
+		Egress:         &EgressProxy{URL: "http://10.89.0.2:3128", runArgs: []string{gateway}},
+		GitBridge:      bridge,
+		SSHAgent:       "/tmp/ssh-XXXX/agent.123",
+	}
+	args, err := opts.BuildArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	argStr := strings.Join(args, " ")
+	for _, want := range []string{
+		"-e NO_PROXY=localhost,127.0.0.1,10.89.0.2",
+		"-e GIT_CONFIG_KEY_0=url.http://10.89.0.2:3129/abc123/github.com/org/repo.git.insteadOf",
+		"-e SSH_AUTH_SOCK=/run/wt/ssh-agent.sock",
+		"-v /tmp/ssh-XXXX/agent.123:/run/wt/ssh-agent.sock:z",
+	} {
+		if !strings.Contains(argStr, want) {
+			t.Errorf("missing %q, got: %s", want, argStr)
+		}
+	}
+	if n := strings.Count(argStr, gateway); n != 1 {
+		t.Errorf("gateway flag appears %d times, want once: %s", n, argStr)
+	}
+
+	opts.Network = NetworkNone
+	opts.Egress = nil
+	if _, err := opts.BuildArgs(); err == nil {
+		t.Error("BuildArgs with a git bridge and no network: expected error")
+	}
+}
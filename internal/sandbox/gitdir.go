@@ -0,0 +1,460 @@
+package sandbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/niref/wt/internal/gitcmd"
+)
+
+// GitIsolation lets a container commit to its linked worktree's branch without
+// being able to rewrite any other ref in the main repository.
+//
+// A linked worktree writes objects and refs into the common .git directory, so a
+// read-only mount breaks `git commit`. Instead the container gets a private copy
+// of the common dir (config, HEAD, hooks, info and all refs as packed-refs)
+// mounted over the real one, with only the object store and the worktree's own
+// admin dir (.git/worktrees/<id>: HEAD, index, logs) bind-mounted writable.
+// Sync copies the worktree's branch back to the real repository afterwards; other
+// ref changes made in the container are discarded, new branches are only created.
+type GitIsolation struct {
+	CommonDir string // Main repository .git directory
+	AdminDir  string // The worktree's admin dir, <CommonDir>/worktrees/<id>
+	Branch    string // Branch checked out in the worktree ("" if detached)
+	stateDir  string // Host-only state: the private common dir and start refs
+	lock      *os.File
+}
+
+// Files in the state dir: startFile records the branch and its commit when the
+// sandbox started, branchesFile the repository's branches then, containerFile
+// the persistent container using the private common dir, which holds no lock
+// once wt exits.
+const (
+	startFile     = "start"
+	branchesFile  = "branches"
+	containerFile = "container"
+)
+
+// ErrGitIsolationInUse is returned by Prepare when another sandbox uses the
+// worktree's private common dir.
+var ErrGitIsolationInUse = errors.New("another sandbox is using this worktree")
+
+// NewGitIsolation returns the isolation for the worktree at wtPath, or nil if
+// wtPath is not a linked worktree (the main checkout has no separate admin dir).
+func NewGitIsolation(wtPath string) (*GitIsolation, error) {
+	adminDir, err := gitcmd.Output(wtPath, nil, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return nil, err
+	}
+	commonDir, err := gitcmd.Output(wtPath, nil, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(wtPath, commonDir)
+	}
+	commonDir = filepath.Clean(commonDir)
+	if commonDir == filepath.Clean(adminDir) {
+		return nil, nil
+	}
+
+	state, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(adminDir))
+
+	g := &GitIsolation{
+		CommonDir: commonDir,
+		AdminDir:  adminDir,
+		stateDir:  filepath.Join(state, "git", hex.EncodeToString(sum[:8])),
+	}
+	g.Branch = CurrentBranch(wtPath)
+	return g, nil
+}
+
+// CurrentBranch returns the branch checked out in the worktree at dir, or "" if
+// its HEAD is detached.
+func CurrentBranch(dir string) string {
+	ref, err := gitcmd.Output(dir, nil, "symbolic-ref", "-q", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
+
+// StateDir returns the directory for wt state, $XDG_STATE_HOME/wt or ~/.local/state/wt.
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "wt"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "wt"), nil
+}
+
+// ShadowDir returns the private copy of the common dir mounted into the container.
+func (g *GitIsolation) ShadowDir() string {
+	return filepath.Join(g.stateDir, "gitdir")
+}
+
+// Prepare locks the worktree's git state and creates the private common dir;
+// the lock is held until Unlock. It fails with ErrGitIsolationInUse while
+// another sandbox holds the lock or a persistent container uses the state.
+// Leftovers of a previous sandbox that exited without syncing are synced first
+// so their commits are not lost.
+func (g *GitIsolation) Prepare() error {
+	if err := g.acquire(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(g.stateDir); err == nil {
+		if container := g.Container(); container != "" {
+			g.Unlock()
+			return fmt.Errorf("%w: persistent sandbox %s (stop it with 'wt sandbox stop')", ErrGitIsolationInUse, container)
+		}
+		if _, err := g.Sync(); err != nil {
+			g.Unlock()
+			return fmt.Errorf("syncing previous sandbox: %w", err)
+		}
+	}
+
+	shadow := g.ShadowDir()
+	for _, dir := range []string{"refs/heads", "refs/tags", "objects", "worktrees/" + filepath.Base(g.AdminDir)} {
+		if err := os.MkdirAll(filepath.Join(shadow, dir), 0755); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{"config", "HEAD", "shallow", "info", "hooks"} {
+		if err := copyTree(filepath.Join(g.CommonDir, name), filepath.Join(shadow, name)); err != nil {
+			return fmt.Errorf("copying %s: %w", name, err)
+		}
+	}
+
+	// Objects may only be reachable from other worktrees, which the container
+	// cannot see: never let it garbage-collect the shared object store.
+	for _, kv := range [][2]string{{"gc.auto", "0"}, {"maintenance.auto", "false"}} {
+		if _, err := gitcmd.Output(g.CommonDir, nil, "config", "--file", filepath.Join(shadow, "config"), kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+
+	refs, err := gitcmd.Output(g.CommonDir, nil, "for-each-ref", "--format=%(objectname) %(refname)")
+	if err != nil {
+		return err
+	}
+	packed := "# pack-refs with: sorted \n"
+	if refs != "" {
+		packed += refs + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(shadow, "packed-refs"), []byte(packed), 0644); err != nil {
+		return err
+	}
+
+	branches, err := listBranches(g.CommonDir, nil)
+	if err != nil {
+		return err
+	}
+	var names string
+	for name := range branches {
+		names += name + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(g.stateDir, branchesFile), []byte(names), 0644); err != nil {
+		return err
+	}
+
+	start := ""
+	if g.Branch != "" {
+		head, _ := gitcmd.Output(g.CommonDir, nil, "rev-parse", "-q", "--verify", "refs/heads/"+g.Branch)
+		start = g.Branch + " " + head + "\n"
+	}
+	return os.WriteFile(filepath.Join(g.stateDir, startFile), []byte(start), 0644)
+}
+
+// acquire takes the exclusive lock on the worktree's git state without
+// waiting. The lock file sits next to the state dir, which Sync removes.
+func (g *GitIsolation) acquire() error {
+	if g.lock != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(g.stateDir), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(g.stateDir+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := tryLock(f); err != nil {
+		f.Close()
+		if errors.Is(err, errLocked) {
+			return ErrGitIsolationInUse
+		}
+		return fmt.Errorf("locking %s: %w", g.stateDir, err)
+	}
+	g.lock = f
+	return nil
+}
+
+// Unlock releases the lock taken by Prepare. It is safe to call more than once.
+func (g *GitIsolation) Unlock() {
+	if g.lock != nil {
+		g.lock.Close()
+		g.lock = nil
+	}
+}
+
+// SetContainer records that the persistent container name uses the private
+// common dir, so Prepare refuses to replace it after wt exits. An empty name
+// clears the record of a container that no longer exists.
+func (g *GitIsolation) SetContainer(name string) error {
+	path := filepath.Join(g.stateDir, containerFile)
+	if name == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(name+"\n"), 0644)
+}
+
+// Container returns the persistent container recorded by SetContainer, or "".
+func (g *GitIsolation) Container() string {
+	data, err := os.ReadFile(filepath.Join(g.stateDir, containerFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Mounts returns the mounts that replace the read-only main .git mount.
+func (g *GitIsolation) Mounts() []Mount {
+	objects := filepath.Join(g.CommonDir, "objects")
+	mounts := []Mount{
+		{Source: g.ShadowDir(), Target: g.CommonDir, Mode: "Z"},
+		// Shared label: sandboxes of other worktrees use the same object store
+		{Source: objects, Target: objects, Mode: "z"},
+		{Source: g.AdminDir, Target: g.AdminDir, Mode: "Z"},
+	}
+	// Submodule repositories stay read-only
+	if modules := filepath.Join(g.CommonDir, "modules"); isDir(modules) {
+		mounts = append(mounts, Mount{Source: modules, Target: modules, Mode: "ro"})
+	}
+	return mounts
+}
+
+// Sync copies the worktree's branch and any newly created branches from the
+// private common dir back to the main repository, then removes the private copy.
+// The worktree's branch is only updated if it still points where it did when the
+// sandbox started, and a new branch only created if the host has no branch of
+// that name; otherwise the sandbox's tip is saved as <branch>-sandbox-<hash>.
+// Other branches that existed when the sandbox started are read-only.
+//
+// It returns a description of each updated ref. If a ref cannot be updated the
+// private copy is kept and the error says how to recover; Sync can be run again,
+// and refs it already copied are left alone. Sync takes the lock if Prepare did
+// not, and fails with ErrGitIsolationInUse if another sandbox holds it.
+func (g *GitIsolation) Sync() ([]string, error) {
+	if g.lock == nil {
+		if err := g.acquire(); err != nil {
+			return nil, err
+		}
+		defer g.Unlock()
+	}
+	data, err := os.ReadFile(filepath.Join(g.stateDir, startFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, os.RemoveAll(g.stateDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	branch, start, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+
+	// Read the private refs with the real object store so they resolve
+	shadowEnv := []string{"GIT_DIR=" + g.ShadowDir(), "GIT_OBJECT_DIRECTORY=" + filepath.Join(g.CommonDir, "objects")}
+	shadowRefs, err := listBranches(g.stateDir, shadowEnv)
+	if err != nil {
+		return nil, g.syncError(err)
+	}
+	realRefs, err := listBranches(g.CommonDir, nil)
+	if err != nil {
+		return nil, g.syncError(err)
+	}
+	// Branches the repository had when the sandbox started; state written
+	// before branchesFile existed falls back to the current ones
+	existed := make(map[string]bool)
+	if data, err := os.ReadFile(filepath.Join(g.stateDir, branchesFile)); err == nil {
+		for _, name := range strings.Fields(string(data)) {
+			existed[name] = true
+		}
+	} else {
+		for name := range realRefs {
+			existed[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(shadowRefs))
+	for name := range shadowRefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var updated []string
+	var errs []string
+	for _, name := range names {
+		commit := shadowRefs[name]
+		var desc string
+		var err error
+		switch {
+		case realRefs[name] == commit:
+			// Unchanged, or copied by an earlier Sync
+			continue
+		case name == branch && commit == start:
+			// Only the host moved it
+			continue
+		case name == branch && realRefs[name] == start:
+			desc = fmt.Sprintf("%s: %s -> %s", name, shortHash(start), shortHash(commit))
+			err = updateBranch(g.CommonDir, name, commit, start)
+		case name == branch:
+			desc, err = saveBranch(g.CommonDir, realRefs, name, commit, "moved outside the sandbox")
+		case existed[name]:
+			// Existing branches other than the worktree's own are read-only
+			continue
+		case realRefs[name] == "":
+			desc = fmt.Sprintf("%s: new branch at %s", name, shortHash(commit))
+			err = updateBranch(g.CommonDir, name, commit, "")
+		default:
+			desc, err = saveBranch(g.CommonDir, realRefs, name, commit, "created outside the sandbox too")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if desc != "" {
+			updated = append(updated, desc)
+		}
+	}
+	if len(errs) > 0 {
+		return updated, g.syncError(errors.New(strings.Join(errs, "; ")))
+	}
+
+	return updated, os.RemoveAll(g.stateDir)
+}
+
+// syncError describes a failed Sync and how to recover from it.
+func (g *GitIsolation) syncError(err error) error {
+	return fmt.Errorf("syncing branches from %s: %w (run the sandbox again to retry, or remove %s to discard the sandbox's commits)", g.ShadowDir(), err, g.stateDir)
+}
+
+// updateBranch points branch at commit if it is at old ("" for a branch that
+// must not exist yet).
+func updateBranch(commonDir, branch, commit, old string) error {
+	_, err := gitcmd.Output(commonDir, nil, "update-ref", "-m", "wt sandbox", "refs/heads/"+branch, commit, old)
+	return err
+}
+
+// saveBranch keeps the sandbox's commit of branch, which the host changed
+// meanwhile, as <branch>-sandbox-<hash>. It returns "" if an earlier Sync
+// already did.
+func saveBranch(commonDir string, realRefs map[string]string, branch, commit, why string) (string, error) {
+	saved := fmt.Sprintf("%s-sandbox-%s", branch, shortHash(commit))
+	if realRefs[saved] == commit {
+		return "", nil
+	}
+	if err := updateBranch(commonDir, saved, commit, ""); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: %s, sandbox commits saved as %s", branch, why, saved), nil
+}
+
+// listBranches returns local branch names mapped to their commits.
+func listBranches(dir string, env []string) (map[string]string, error) {
+	out, err := gitcmd.Output(dir, env, "for-each-ref", "--format=%(objectname) %(refname:lstrip=2)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	branches := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if commit, name, ok := strings.Cut(line, " "); ok {
+			branches[name] = commit
+		}
+	}
+	return branches, nil
+}
+
+// shortHash abbreviates a commit hash for display.
+func shortHash(hash string) string {
+	if hash == "" {
+		return "(none)"
+	}
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// copyTree copies a file or directory tree; a missing src is not an error.
+func copyTree(src, dst string) error {
+	if _, err := os.Lstat(src); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// copyFile copies a regular file, preserving its permission bits.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,311 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/niref/wt/internal/gitcmd"
+)
+
+// git runs git in dir with extra environment, failing the test on error.
+func git(t *testing.T, dir string, env []string, args ...string) string {
+	t.Helper()
+	out, err := gitcmd.Output(dir, env, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// setupLinkedWorktree creates a repo with one commit and a linked worktree on branch feature.
+func setupLinkedWorktree(t *testing.T) (repo, wtPath string) {
+	t.Helper()
+	tmp := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmp, "state"))
+	repo = filepath.Join(tmp, "repo")
+	wtPath = filepath.Join(repo, ".claude", "worktrees", "feature")
+
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+	git(t, repo, nil, "config", "user.email", "test@test.com")
+	git(t, repo, nil, "config", "user.name", "Test")
+	git(t, repo, nil, "commit", "--allow-empty", "-m", "initial")
+	git(t, repo, nil, "worktree", "add", "-b", "feature", wtPath)
+	return repo, wtPath
+}
+
+// containerEnv simulates the container's view of the worktree for git commands:
+// the admin dir's commondir points at the private common dir, whose empty object
+// store is replaced by the real one. Call the returned function to undo it.
+func containerEnv(t *testing.T, g *GitIsolation) ([]string, func()) {
+	t.Helper()
+	commondir := filepath.Join(g.AdminDir, "commondir")
+	orig, err := os.ReadFile(commondir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(commondir, []byte(g.ShadowDir()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := []string{"GIT_OBJECT_DIRECTORY=" + filepath.Join(g.CommonDir, "objects")}
+	return env, func() { os.WriteFile(commondir, orig, 0644) }
+}
+
+func TestNewGitIsolation_MainCheckout(t *testing.T) {
+	repo, _ := setupLinkedWorktree(t)
+
+	g, err := NewGitIsolation(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g != nil {
+		t.Errorf("NewGitIsolation(main checkout) = %+v, want nil", g)
+	}
+}
+
+func TestGitIsolation_CommitAndSync(t *testing.T) {
+	repo, wtPath := setupLinkedWorktree(t)
+	mainBefore := git(t, repo, nil, "rev-parse", "HEAD")
+
+	g, err := NewGitIsolation(wtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g == nil || g.Branch != "feature" || g.CommonDir != filepath.Join(repo, ".git") {
+		t.Fatalf("NewGitIsolation() = %+v", g)
+	}
+	if err := g.Prepare(); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	container, restore := containerEnv(t, g)
+	if err := os.WriteFile(filepath.Join(wtPath, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, wtPath, container, "add", "a.txt")
+	git(t, wtPath, container, "commit", "-m", "in sandbox")
+	committed := git(t, wtPath, container, "rev-parse", "HEAD")
+	git(t, wtPath, container, "update-ref", "refs/heads/main", committed)
+	git(t, wtPath, container, "branch", "scratch", committed)
+	restore()
+
+	if got := git(t, repo, nil, "rev-parse", "feature"); got == committed {
+		t.Fatal("real branch moved before Sync")
+	}
+
+	updated, err := g.Sync()
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(updated) != 2 {
+		t.Errorf("Sync() updated %v, want feature and scratch", updated)
+	}
+	if got := git(t, repo, nil, "rev-parse", "feature"); got != committed {
+		t.Errorf("feature = %s, want %s", got, committed)
+	}
+	if got := git(t, repo, nil, "rev-parse", "scratch"); got != committed {
+		t.Errorf("scratch = %s, want %s", got, committed)
+	}
+	if got := git(t, repo, nil, "rev-parse", "main"); got != mainBefore {
+		t.Errorf("main was rewritten from the sandbox: %s", got)
+	}
+	if _, err := os.Stat(g.stateDir); !os.IsNotExist(err) {
+		t.Errorf("state dir should be removed after Sync, stat err = %v", err)
+	}
+	if status := git(t, wtPath, nil, "status", "--porcelain"); status != "" {
+		t.Errorf("worktree should be clean after Sync, got %q", status)
+	}
+}
+
+func TestGitIsolation_Lock(t *testing.T) {
+	_, wtPath := setupLinkedWorktree(t)
+
+	first, err := NewGitIsolation(wtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Prepare(); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	shadow, err := os.Stat(first.ShadowDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second sandbox must not replace the running one's private common dir
+	second, err := NewGitIsolation(wtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Prepare(); !errors.Is(err, ErrGitIsolationInUse) {
+		t.Fatalf("second Prepare() error = %v, want ErrGitIsolationInUse", err)
+	}
+	if _, err := second.Sync(); !errors.Is(err, ErrGitIsolationInUse) {
+		t.Fatalf("Sync() while locked error = %v, want ErrGitIsolationInUse", err)
+	}
+	if info, err := os.Stat(first.ShadowDir()); err != nil || !os.SameFile(info, shadow) {
+		t.Fatalf("private common dir was replaced: %v", err)
+	}
+
+	// A persistent container keeps it after wt exits
+	if err := first.SetContainer("wt-repo-feature"); err != nil {
+		t.Fatal(err)
+	}
+	first.Unlock()
+	err = second.Prepare()
+	if !errors.Is(err, ErrGitIsolationInUse) || !strings.Contains(err.Error(), "wt-repo-feature") {
+		t.Fatalf("Prepare() with a persistent container error = %v", err)
+	}
+
+	// Once the container is gone, leftovers are synced and the dir is recreated
+	if err := second.SetContainer(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Prepare(); err != nil {
+		t.Fatalf("Prepare() after clearing the container error = %v", err)
+	}
+	second.Unlock()
+}
+
+func TestGitIsolation_SyncKeepsMovedBranch(t *testing.T) {
+	repo, wtPath := setupLinkedWorktree(t)
+
+	g, err := NewGitIsolation(wtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	container, restore := containerEnv(t, g)
+	git(t, wtPath, container, "commit", "--allow-empty", "-m", "in sandbox")
+	sandboxTip := git(t, wtPath, container, "rev-parse", "HEAD")
+	restore()
+
+	// The branch moves on the host while the sandbox runs
+	hostTip := git(t, repo, nil, "commit-tree", "HEAD^{tree}", "-p", "HEAD", "-m", "host")
+	git(t, repo, nil, "update-ref", "refs/heads/feature", hostTip)
+
+	updated, err := g.Sync()
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(updated) != 1 || !strings.Contains(updated[0], "feature-sandbox-"+shortHash(sandboxTip)) {
+		t.Errorf("Sync() = %v, want sandbox commits saved on a side branch", updated)
+	}
+	if got := git(t, repo, nil, "rev-parse", "feature"); got != hostTip {
+		t.Errorf("feature = %s, want host commit %s", got, hostTip)
+	}
+}
+
+func TestGitIsolation_SyncRetry(t *testing.T) {
+	repo, wtPath := setupLinkedWorktree(t)
+
+	g, err := NewGitIsolation(wtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	container, restore := containerEnv(t, g)
+	git(t, wtPath, container, "commit", "--allow-empty", "-m", "in sandbox")
+	sandboxTip := git(t, wtPath, container, "rev-parse", "HEAD")
+	git(t, wtPath, container, "branch", "topic", sandboxTip)
+	restore()
+
+	// A stale lock makes creating topic fail
+	lock := filepath.Join(repo, ".git", "refs", "heads", "topic.lock")
+	if err := os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := g.Sync()
+	if err == nil || !strings.Contains(err.Error(), "remove "+g.stateDir) {
+		t.Fatalf("Sync() error = %v, want how to recover", err)
+	}
+	if len(updated) != 1 || !strings.HasPrefix(updated[0], "feature: ") {
+		t.Errorf("Sync() = %v, want feature updated", updated)
+	}
+	if _, err := os.Stat(g.stateDir); err != nil {
+		t.Fatalf("state dir removed after a failed Sync: %v", err)
+	}
+
+	// The retry copies topic and leaves the already updated feature alone
+	if err := os.Remove(lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Prepare(); err != nil {
+		t.Fatalf("Prepare() after a failed Sync = %v", err)
+	}
+	g.Unlock()
+	if got := git(t, repo, nil, "rev-parse", "topic"); got != sandboxTip {
+		t.Errorf("topic = %s, want %s", got, sandboxTip)
+	}
+	if got := git(t, repo, nil, "rev-parse", "feature"); got != sandboxTip {
+		t.Errorf("feature = %s, want %s", got, sandboxTip)
+	}
+	if branches := git(t, repo, nil, "branch", "--list", "*-sandbox-*"); branches != "" {
+		t.Errorf("retry saved side branches: %s", branches)
+	}
+}
+
+func TestGitIsolation_SyncKeepsNewBranchOfSameName(t *testing.T) {
+	repo, wtPath := setupLinkedWorktree(t)
+
+	g, err := NewGitIsolation(wtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	container, restore := containerEnv(t, g)
+	git(t, wtPath, container, "commit", "--allow-empty", "-m", "in sandbox")
+	sandboxTip := git(t, wtPath, container, "rev-parse", "HEAD")
+	git(t, wtPath, container, "branch", "topic", sandboxTip)
+	// Reset feature so only topic has the sandbox's commit
+	git(t, wtPath, container, "reset", "-q", "--hard", "HEAD^")
+	restore()
+
+	// The host creates a branch of the same name meanwhile
+	hostTip := git(t, repo, nil, "commit-tree", "HEAD^{tree}", "-p", "HEAD", "-m", "host")
+	git(t, repo, nil, "branch", "topic", hostTip)
+
+	updated, err := g.Sync()
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	saved := "topic-sandbox-" + shortHash(sandboxTip)
+	if len(updated) != 1 || !strings.Contains(updated[0], saved) {
+		t.Errorf("Sync() = %v, want the sandbox's topic saved as %s", updated, saved)
+	}
+	if got := git(t, repo, nil, "rev-parse", "topic"); got != hostTip {
+		t.Errorf("topic = %s, want host commit %s", got, hostTip)
+	}
+	if got := git(t, repo, nil, "rev-parse", saved); got != sandboxTip {
+		t.Errorf("%s = %s, want %s", saved, got, sandboxTip)
+	}
+}
+
+func TestGitIsolation_Mounts(t *testing.T) {
+	g := &GitIsolation{
+		CommonDir: "/repo/.git",
+		AdminDir:  "/repo/.git/worktrees/feature",
+		stateDir:  "/state/wt/git/abc",
+	}
+	want := []Mount{
+		{Source: "/state/wt/git/abc/gitdir", Target: "/repo/.git", Mode: "Z"},
+		{Source: "/repo/.git/objects", Target: "/repo/.git/objects", Mode: "z"},
+		{Source: "/repo/.git/worktrees/feature", Target: "/repo/.git/worktrees/feature", Mode: "Z"},
+	}
+	if got := g.Mounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Mounts() = %+v, want %+v", got, want)
+	}
+}
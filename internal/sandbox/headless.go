@@ -0,0 +1,199 @@
+package sandbox
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Files written to a headless run's log directory.
+const (
+	PromptFile     = "prompt.md"
+	TranscriptFile = "transcript.jsonl" // Claude's stream-json output
+	StderrFile     = "stderr.log"
+	StatusFile     = "status.json"
+)
+
+// RunStatus is the outcome of a headless run, saved as status.json.
+type RunStatus struct {
+	Worktree string    `json:"worktree"`
+	ExitCode int       `json:"exit_code"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Result   string    `json:"result,omitempty"`   // Claude's final message
+	IsError  bool      `json:"is_error,omitempty"` // Claude reported an error result
+	NumTurns int       `json:"num_turns,omitempty"`
+	CostUSD  float64   `json:"cost_usd,omitempty"`
+}
+
+// Duration returns how long the run took.
+func (s RunStatus) Duration() time.Duration {
+	return s.Finished.Sub(s.Started)
+}
+
+// LogDir returns the directory holding headless run logs of a worktree:
+// <state>/logs/<repo>-<hash>/<worktree>/.
+func LogDir(repoRoot, worktree string) (string, error) {
+	state, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(state, "logs", repoKey(repoRoot), worktree), nil
+}
+
+// repoKey names a repository's directory or file under the state directory:
+// <repo>-<hash>, unique per repository root.
+func repoKey(repoRoot string) string {
+	sum := sha256.Sum256([]byte(repoRoot))
+	return filepath.Base(repoRoot) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// NewRunDir creates a timestamped directory for one run under logDir.
+func NewRunDir(logDir string, now time.Time) (string, error) {
+	dir := filepath.Join(logDir, now.Format("20060102-150405"))
+	for i := 2; ; i++ {
+		err := os.MkdirAll(filepath.Dir(dir), 0755)
+		if err == nil {
+			err = os.Mkdir(dir, 0755)
+		}
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+		dir = filepath.Join(logDir, fmt.Sprintf("%s-%d", now.Format("20060102-150405"), i))
+	}
+}
+
+// RunHeadless runs Claude in print mode with opts.Prompt. Claude's stream-json
+// output is saved to runDir/transcript.jsonl and rendered readably to out as it
+// arrives; the outcome is saved to runDir/status.json. A non-zero ExitCode is not
+// an error; errors mean the run could not be started or logged.
+func RunHeadless(opts *Options, runDir string, out io.Writer) (RunStatus, error) {
+	status := RunStatus{Worktree: opts.WorktreePath}
+	if opts.Prompt == "" {
+		return status, fmt.Errorf("headless run needs a prompt")
+	}
+
+	// Only readable by the user: the prompt may describe unreleased work
+	opts.PromptPath = filepath.Join(runDir, PromptFile)
+	if err := os.WriteFile(opts.PromptPath, []byte(opts.Prompt), 0600); err != nil {
+		return status, err
+	}
+	args, err := opts.BuildArgs()
+	if err != nil {
+		return status, err
+	}
+	args = append(args, "bash", "-c", opts.innerCommand())
+
+	transcript, err := os.Create(filepath.Join(runDir, TranscriptFile))
+	if err != nil {
+		return status, err
+	}
+	defer transcript.Close()
+	stderr, err := os.Create(filepath.Join(runDir, StderrFile))
+	if err != nil {
+		return status, err
+	}
+	defer stderr.Close()
+
+	cmd := exec.Command(opts.runtime().Name(), args...)
+	cmd.Stderr = io.MultiWriter(stderr, out)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return status, err
+	}
+
+	status.Started = time.Now()
+	if err := cmd.Start(); err != nil {
+		return status, err
+	}
+	renderTranscript(io.TeeReader(stdout, transcript), out, &status)
+	err = cmd.Wait()
+	status.Finished = time.Now()
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		status.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return status, err
+	case status.IsError:
+		status.ExitCode = 1
+	}
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return status, err
+	}
+	return status, os.WriteFile(filepath.Join(runDir, StatusFile), append(data, '\n'), 0644)
+}
+
+// streamEvent is the subset of Claude's stream-json events that is rendered.
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+	} `json:"message"`
+	Result   string  `json:"result"`
+	IsError  bool    `json:"is_error"`
+	NumTurns int     `json:"num_turns"`
+	CostUSD  float64 `json:"total_cost_usd"`
+}
+
+// renderTranscript prints assistant text and tool calls from stream-json on r
+// and records the final result in status. Lines that are not JSON are printed as is.
+func renderTranscript(r io.Reader, out io.Writer, status *RunStatus) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var ev streamEvent
+		if err := json.Unmarshal(line, &ev); err != nil || ev.Type == "" {
+			fmt.Fprintf(out, "%s\n", line)
+			continue
+		}
+		switch ev.Type {
+		case "assistant":
+			for _, c := range ev.Message.Content {
+				switch c.Type {
+				case "text":
+					fmt.Fprintln(out, strings.TrimSpace(c.Text))
+				case "tool_use":
+					fmt.Fprintf(out, "→ %s %s\n", c.Name, truncate(string(c.Input), 100))
+				}
+			}
+		case "result":
+			status.Result = ev.Result
+			status.IsError = ev.IsError
+			status.NumTurns = ev.NumTurns
+			status.CostUSD = ev.CostUSD
+		}
+	}
+	// Drain the rest so the container never blocks on a full pipe
+	io.Copy(io.Discard, r)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
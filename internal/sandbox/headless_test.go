@@ -0,0 +1,86 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildArgsPrompt(t *testing.T) {
+	opts := &Options{
+		WorktreePath:   "/tmp/test-worktree",
+		ContainerImage: "wt-sandbox",
+		StartClaude:    true,
+		Prompt:         "fix the tests",
+	}
+	if _, err := opts.BuildArgs(); err == nil {
+		t.Error("BuildArgs without PromptPath: expected error")
+	}
+	opts.PromptPath = "/state/logs/app/feature/20260301-120000/prompt.md"
+
+	args, err := opts.BuildArgs()
+	if err != nil {
+		t.Fatalf("BuildArgs failed: %v", err)
+	}
+
+	argStr := strings.Join(args, " ")
+	if strings.Contains(argStr, "-it") {
+		t.Errorf("headless run should not allocate a TTY, got: %s", argStr)
+	}
+	if strings.Contains(argStr, "fix the tests") {
+		t.Errorf("prompt on the command line: %s", argStr)
+	}
+	if !strings.Contains(argStr, "-v "+opts.PromptPath+":/run/wt/prompt:ro") {
+		t.Errorf("missing read-only prompt mount, got: %s", argStr)
+	}
+
+	inner := opts.innerCommand()
+	if !strings.Contains(inner, "-p --output-format stream-json --verbose < /run/wt/prompt") {
+		t.Errorf("inner command does not run claude headless: %s", inner)
+	}
+}
+
+func TestRenderTranscript(t *testing.T) {
+	transcript := `{"type":"system","subtype":"init"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Running the tests.\n"},{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}
+not json
+{"type":"result","result":"All tests pass.","is_error":false,"num_turns":3,"total_cost_usd":0.25}
+`
+	var out strings.Builder
+	var status RunStatus
+	renderTranscript(strings.NewReader(transcript), &out, &status)
+
+	want := "Running the tests.\n→ Bash {\"command\":\"go test ./...\"}\nnot json\n"
+	if out.String() != want {
+		t.Errorf("rendered:\n%q\nwant:\n%q", out.String(), want)
+	}
+	if status.Result != "All tests pass." || status.IsError || status.NumTurns != 3 || status.CostUSD != 0.25 {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestNewRunDir(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs")
+	now := time.Date(2025, 3, 1, 14, 30, 0, 0, time.UTC)
+
+	first, err := NewRunDir(logDir, now)
+	if err != nil {
+		t.Fatalf("NewRunDir failed: %v", err)
+	}
+	second, err := NewRunDir(logDir, now)
+	if err != nil {
+		t.Fatalf("NewRunDir failed: %v", err)
+	}
+
+	if filepath.Base(first) != "20250301-143000" {
+		t.Errorf("first run dir = %s", first)
+	}
+	if filepath.Base(second) != "20250301-143000-2" {
+		t.Errorf("second run dir = %s", second)
+	}
+	if info, err := os.Stat(second); err != nil || !info.IsDir() {
+		t.Errorf("run dir not created: %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package sandbox
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LabelContainerfileHash is set on images wt builds to the ContainerfileHash they
+// were built from, so a changed Containerfile triggers a rebuild.
+const LabelContainerfileHash = "wt.containerfile-hash"
+
+// OverlayFile holds a repository's additions to the sandbox image: Containerfile
+// instructions without a FROM line, layered on the base sandbox image.
+const OverlayFile = ".wt/Containerfile.extra"
+
+// BuildOptions configures an image build.
+type BuildOptions struct {
+	Containerfile string
+	Image         string // Tag of the built image
+	Hash          string // ContainerfileHash or OverlayHash, recorded in a label
+	NoCache       bool   // Rebuild every layer
+	Base          string // Image an overlay Containerfile is layered on
+	Context       string // Build context; default the Containerfile's directory
+	BuildArgs     map[string]string
+}
+
+// BuildImage builds a sandbox image. Unless opts.Context is set, the build
+// context is the Containerfile's directory, so COPY sources are relative to it.
+func BuildImage(rt Runtime, opts BuildOptions) error {
+	containerfile := opts.Containerfile
+	if opts.Base != "" {
+		layered, err := layerOnBase(opts.Containerfile, opts.Base)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(layered)
+		containerfile = layered
+	}
+
+	args := []string{"build", "-t", opts.Image, "-f", containerfile}
+	if opts.Hash != "" {
+		args = append(args, "--label", LabelContainerfileHash+"="+opts.Hash)
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	buildArgs := make([]string, 0, len(opts.BuildArgs))
+	for k, v := range opts.BuildArgs {
+		buildArgs = append(buildArgs, k+"="+v)
+	}
+	sort.Strings(buildArgs)
+	for _, arg := range buildArgs {
+		args = append(args, "--build-arg", arg)
+	}
+	context := opts.Context
+	if context == "" {
+		context = filepath.Dir(opts.Containerfile)
+	}
+	cmd := exec.Command(rt.Name(), append(args, context)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// layerOnBase writes a temporary Containerfile that starts FROM base and
+// continues with the instructions of overlay, and returns its path.
+func layerOnBase(overlay, base string) (string, error) {
+	data, err := os.ReadFile(overlay)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "wt-Containerfile-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = fmt.Fprintf(f, "FROM %s\n%s", base, data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// FindOverlay returns the path of the repository's OverlayFile, or "" if it has none.
+func FindOverlay(repoRoot string) string {
+	path := filepath.Join(repoRoot, OverlayFile)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// RepoImageName returns the tag of a repository's image layered on base:
+// <base>-<repo>-<hash>, lowercased as image names must be.
+func RepoImageName(base, repoRoot string) string {
+	sum := sha256.Sum256([]byte(repoRoot))
+	name := strings.ToLower(invalidNameChars.ReplaceAllString(filepath.Base(repoRoot), "-"))
+	if tag := strings.LastIndex(base, ":"); tag > strings.LastIndex(base, "/") {
+		base = base[:tag]
+	}
+	return base + "-" + strings.Trim(name, "-._") + "-" + hex.EncodeToString(sum[:4])
+}
+
+// ImageExists checks if an image exists locally
+func ImageExists(rt Runtime, imageName string) bool {
+	return ImageID(rt, imageName) != ""
+}
+
+// ImageID returns the ID of a local image, or "" if it does not exist.
+func ImageID(rt Runtime, imageName string) string {
+	out, err := exec.Command(rt.Name(), "image", "inspect", "--format", "{{.Id}}", imageName).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ImageHash returns the ContainerfileHash label of a local image, or "" if the
+// image does not exist or was not built by wt.
+func ImageHash(rt Runtime, imageName string) string {
+	format := fmt.Sprintf("{{index .Config.Labels %q}}", LabelContainerfileHash)
+	out, err := exec.Command(rt.Name(), "image", "inspect", "--format", format, imageName).Output()
+	if err != nil {
+		return ""
+	}
+	return labelValue(string(out))
+}
+
+// Image is a sandbox image built by wt.
+type Image struct {
+	ID      string    `json:"id"`
+	Tags    []string  `json:"tags"` // Empty once a rebuild has moved the tag to a newer image
+	Created time.Time `json:"created"`
+	Hash    string    `json:"hash"` // ContainerfileHash the image was built from
+}
+
+// ListImages returns the local images built by wt, newest first.
+func ListImages(rt Runtime) ([]Image, error) {
+	out, err := exec.Command(rt.Name(), "images", "-q", "--no-trunc", "--filter", "label="+LabelContainerfileHash).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s images: %w", rt.Name(), err)
+	}
+	// Images with several tags are listed once per tag
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Fields(string(out)) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	format := fmt.Sprintf(`{{.Id}}{{"\t"}}{{range .RepoTags}}{{.}} {{end}}{{"\t"}}{{.Created}}{{"\t"}}{{index .Config.Labels %q}}`, LabelContainerfileHash)
+	out, err = exec.Command(rt.Name(), append([]string{"image", "inspect", "--format", format}, ids...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s image inspect: %w", rt.Name(), err)
+	}
+	return parseImages(string(out)), nil
+}
+
+// parseImages parses the tab-separated inspect output of ListImages.
+func parseImages(out string) []Image {
+	var images []Image
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		img := Image{
+			ID:   strings.TrimPrefix(fields[0], "sha256:"),
+			Tags: strings.Fields(fields[1]),
+			Hash: labelValue(fields[3]),
+		}
+		// Docker prints RFC 3339, Podman Go's default time format
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999 -0700 MST"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(fields[2])); err == nil {
+				img.Created = t
+				break
+			}
+		}
+		images = append(images, img)
+	}
+	sort.SliceStable(images, func(i, j int) bool { return images[i].Created.After(images[j].Created) })
+	return images
+}
+
+// RemoveImage removes a local image.
+func RemoveImage(rt Runtime, id string) error {
+	return runQuiet(rt, "rmi", id)
+}
+
+// labelValue cleans up a label printed by an inspect template; templates print
+// "<no value>" for labels that are not set.
+func labelValue(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "<no value>" {
+		return ""
+	}
+	return s
+}
+
+// ContainerfileHash hashes a Containerfile and the files its COPY and ADD
+// instructions take from the build context (the Containerfile's directory), so
+// any change that affects the build changes the hash. Sources from other stages
+// (--from) and URLs are not hashed.
+func ContainerfileHash(containerfile string) (string, error) {
+	return contextHash(containerfile, filepath.Dir(containerfile), "")
+}
+
+// OverlayHash hashes an overlay Containerfile like ContainerfileHash, together
+// with the ID of the base image it is layered on, so rebuilding the base
+// triggers a rebuild of the overlay.
+func OverlayHash(overlay, baseID string) (string, error) {
+	return contextHash(overlay, filepath.Dir(overlay), baseID)
+}
+
+// contextHash hashes prefix, a Containerfile and its COPY and ADD sources in contextDir.
+func contextHash(containerfile, contextDir, prefix string) (string, error) {
+	data, err := os.ReadFile(containerfile)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if prefix != "" {
+		fmt.Fprintf(h, "%s\x00", prefix)
+	}
+	h.Write(data)
+	for _, src := range copySources(string(data)) {
+		matches, err := filepath.Glob(filepath.Join(contextDir, src))
+		if err != nil {
+			return "", fmt.Errorf("%s: bad COPY source %q: %w", containerfile, src, err)
+		}
+		if len(matches) == 0 {
+			// The build will fail; still let the hash reflect the missing source
+			fmt.Fprintf(h, "missing %s\x00", src)
+		}
+		for _, match := range matches {
+			if err := hashTree(h, contextDir, match); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// copySources returns the context paths used by COPY and ADD instructions.
+func copySources(containerfile string) []string {
+	var sources []string
+	scanner := bufio.NewScanner(strings.NewReader(containerfile))
+	var instruction string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if continued, ok := strings.CutSuffix(line, "\\"); ok {
+			instruction += continued + " "
+			continue
+		}
+		instruction += line
+		sources = append(sources, instructionSources(instruction)...)
+		instruction = ""
+	}
+	return sources
+}
+
+// instructionSources returns the context sources of a single COPY or ADD instruction.
+func instructionSources(instruction string) []string {
+	keyword, rest, _ := strings.Cut(strings.TrimSpace(instruction), " ")
+	if keyword = strings.ToUpper(keyword); keyword != "COPY" && keyword != "ADD" {
+		return nil
+	}
+
+	rest = strings.TrimSpace(rest)
+	for strings.HasPrefix(rest, "--") {
+		var flag string
+		flag, rest, _ = strings.Cut(rest, " ")
+		if strings.HasPrefix(flag, "--from") {
+			return nil
+		}
+		rest = strings.TrimSpace(rest)
+	}
+
+	var args []string
+	if strings.HasPrefix(rest, "[") {
+		if err := json.Unmarshal([]byte(rest), &args); err != nil {
+			return nil
+		}
+	} else {
+		args = strings.Fields(rest)
+	}
+	if len(args) < 2 {
+		return nil
+	}
+
+	var sources []string
+	for _, src := range args[:len(args)-1] {
+		if strings.Contains(src, "://") || strings.HasPrefix(src, "<<") {
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// hashTree writes the paths (relative to root) and contents of the files under path to w.
+func hashTree(w io.Writer, root, path string) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			fmt.Fprintf(w, "%s\x00link %s\x00", filepath.ToSlash(rel), target)
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\x00%d\x00", filepath.ToSlash(rel), info.Size())
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
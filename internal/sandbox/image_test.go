@@ -0,0 +1,145 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopySources(t *testing.T) {
+	containerfile := `FROM debian AS build
+# COPY commented.txt /nowhere
+COPY setup.sh /usr/local/bin/
+ADD --chown=1000:1000 config/ \
+    extra.conf /etc/app/
+COPY ["with space.txt", "/opt/"]
+COPY --from=build /out /out
+ADD https://example.com/tool.tar.gz /tmp/
+copy lower.txt /tmp/
+RUN echo COPY not-an-instruction /x
+`
+	got := copySources(containerfile)
+	want := []string{"setup.sh", "config/", "extra.conf", "with space.txt", "lower.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("copySources = %q, want %q", got, want)
+	}
+}
+
+func TestContainerfileHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		h, err := ContainerfileHash(filepath.Join(dir, "Containerfile"))
+		if err != nil {
+			t.Fatalf("ContainerfileHash failed: %v", err)
+		}
+		return h
+	}
+
+	write("Containerfile", "FROM debian\nCOPY scripts/ /opt/scripts/\n")
+	write("scripts/setup.sh", "echo one")
+	write("unrelated.txt", "one")
+	first := hash()
+
+	write("unrelated.txt", "two")
+	if hash() != first {
+		t.Error("hash changed for a file the Containerfile does not copy")
+	}
+
+	write("scripts/setup.sh", "echo two")
+	second := hash()
+	if second == first {
+		t.Error("hash unchanged after a copied file changed")
+	}
+
+	write("Containerfile", "FROM debian\nCOPY scripts/ /opt/scripts/\nRUN true\n")
+	if hash() == second {
+		t.Error("hash unchanged after the Containerfile changed")
+	}
+}
+
+func TestParseImages(t *testing.T) {
+	out := "sha256:aaa\tlocalhost/wt-sandbox:latest \t2025-03-01 14:30:00.5 +0000 UTC\tabc123\n" +
+		"sha256:bbb\t\t2025-02-01T10:00:00.123Z\t<no value>\n"
+	got := parseImages(out)
+	want := []Image{
+		{ID: "aaa", Tags: []string{"localhost/wt-sandbox:latest"}, Created: time.Date(2025, 3, 1, 14, 30, 0, 5e8, time.UTC), Hash: "abc123"},
+		{ID: "bbb", Tags: []string{}, Created: time.Date(2025, 2, 1, 10, 0, 0, 123e6, time.UTC)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseImages returned %d images, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || !reflect.DeepEqual(got[i].Tags, want[i].Tags) || !got[i].Created.Equal(want[i].Created) || got[i].Hash != want[i].Hash {
+			t.Errorf("image %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRepoImageName(t *testing.T) {
+	got := RepoImageName("wt-sandbox", "/home/user/dev/My Repo")
+	if !strings.HasPrefix(got, "wt-sandbox-my-repo-") || len(got) != len("wt-sandbox-my-repo-")+8 {
+		t.Errorf("RepoImageName = %q, want wt-sandbox-my-repo-<hash>", got)
+	}
+	if other := RepoImageName("wt-sandbox", "/other/My Repo"); other == got {
+		t.Error("repositories with the same name should get different images")
+	}
+	if got := RepoImageName("registry.example.com:5000/sandbox:v2", "/src/app"); !strings.HasPrefix(got, "registry.example.com:5000/sandbox-app-") {
+		t.Errorf("RepoImageName should drop the base tag, got %q", got)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	repo := t.TempDir()
+	if FindOverlay(repo) != "" {
+		t.Error("FindOverlay should be empty without an overlay file")
+	}
+	overlay := filepath.Join(repo, OverlayFile)
+	if err := os.MkdirAll(filepath.Dir(overlay), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overlay, []byte("RUN apt-get install -y postgresql-client\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if FindOverlay(repo) != overlay {
+		t.Errorf("FindOverlay = %q, want %q", FindOverlay(repo), overlay)
+	}
+
+	layered, err := layerOnBase(overlay, "wt-sandbox")
+	if err != nil {
+		t.Fatalf("layerOnBase failed: %v", err)
+	}
+	defer os.Remove(layered)
+	data, err := os.ReadFile(layered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "FROM wt-sandbox\nRUN apt-get install -y postgresql-client\n"; string(data) != want {
+		t.Errorf("layered Containerfile = %q, want %q", data, want)
+	}
+
+	first, err := OverlayHash(overlay, "sha256:aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := OverlayHash(overlay, "sha256:bbb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("OverlayHash should change with the base image")
+	}
+}
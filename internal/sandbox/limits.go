@@ -0,0 +1,73 @@
+package sandbox
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Limits caps the resources a sandbox container may use. Zero values mean no limit.
+type Limits struct {
+	CPUs      string `json:"cpus,omitempty"`       // Number of CPUs, e.g. "2" or "1.5"
+	Memory    string `json:"memory,omitempty"`     // Memory limit, e.g. "4g"; swap is not allowed beyond it
+	PidsLimit int    `json:"pids_limit,omitempty"` // Maximum number of processes
+	DiskQuota string `json:"disk_quota,omitempty"` // Size of the container's writable layer, e.g. "20g"
+}
+
+// sizePattern matches the sizes container runtimes accept, e.g. 512m or 4g.
+var sizePattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// Validate checks that the limits are well-formed.
+func (l Limits) Validate() error {
+	if l.CPUs != "" {
+		if n, err := strconv.ParseFloat(l.CPUs, 64); err != nil || n <= 0 {
+			return fmt.Errorf("invalid CPU limit %q: want a positive number", l.CPUs)
+		}
+	}
+	if l.Memory != "" && !sizePattern.MatchString(l.Memory) {
+		return fmt.Errorf("invalid memory limit %q: want a size like 512m or 4g", l.Memory)
+	}
+	if l.PidsLimit < 0 {
+		return fmt.Errorf("invalid pids limit %d", l.PidsLimit)
+	}
+	if l.DiskQuota != "" && !sizePattern.MatchString(l.DiskQuota) {
+		return fmt.Errorf("invalid disk quota %q: want a size like 20g", l.DiskQuota)
+	}
+	return nil
+}
+
+// Args returns the run flags for the limits. The disk quota needs a storage
+// driver with quota support (overlay on XFS with pquota).
+func (l Limits) Args() []string {
+	var args []string
+	if l.CPUs != "" {
+		args = append(args, "--cpus="+l.CPUs)
+	}
+	if l.Memory != "" {
+		args = append(args, "--memory="+l.Memory, "--memory-swap="+l.Memory)
+	}
+	if l.PidsLimit > 0 {
+		args = append(args, "--pids-limit="+strconv.Itoa(l.PidsLimit))
+	}
+	if l.DiskQuota != "" {
+		args = append(args, "--storage-opt", "size="+l.DiskQuota)
+	}
+	return args
+}
+
+// Merge returns l with fields set in override replacing its own.
+func (l Limits) Merge(override Limits) Limits {
+	if override.CPUs != "" {
+		l.CPUs = override.CPUs
+	}
+	if override.Memory != "" {
+		l.Memory = override.Memory
+	}
+	if override.PidsLimit != 0 {
+		l.PidsLimit = override.PidsLimit
+	}
+	if override.DiskQuota != "" {
+		l.DiskQuota = override.DiskQuota
+	}
+	return l
+}
@@ -0,0 +1,41 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLimitsArgs(t *testing.T) {
+	l := Limits{CPUs: "1.5", Memory: "4g", PidsLimit: 512, DiskQuota: "20g"}
+	want := []string{"--cpus=1.5", "--memory=4g", "--memory-swap=4g", "--pids-limit=512", "--storage-opt", "size=20g"}
+	if got := l.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %v, want %v", got, want)
+	}
+	if got := (Limits{}).Args(); got != nil {
+		t.Errorf("Args() with no limits = %v, want none", got)
+	}
+}
+
+func TestLimitsValidate(t *testing.T) {
+	valid := []Limits{{}, {CPUs: "2"}, {Memory: "512m"}, {Memory: "1073741824"}, {PidsLimit: 100}, {DiskQuota: "10G"}}
+	for _, l := range valid {
+		if err := l.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", l, err)
+		}
+	}
+	invalid := []Limits{{CPUs: "0"}, {CPUs: "two"}, {Memory: "4 GB"}, {PidsLimit: -1}, {DiskQuota: "big"}}
+	for _, l := range invalid {
+		if err := l.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", l)
+		}
+	}
+}
+
+func TestLimitsMerge(t *testing.T) {
+	repo := Limits{CPUs: "2", Memory: "4g", PidsLimit: 512}
+	got := repo.Merge(Limits{Memory: "8g", DiskQuota: "20g"})
+	want := Limits{CPUs: "2", Memory: "8g", PidsLimit: 512, DiskQuota: "20g"}
+	if got != want {
+		t.Errorf("Merge() = %+v, want %+v", got, want)
+	}
+}
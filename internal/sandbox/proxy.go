@@ -0,0 +1,197 @@
+package sandbox
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAllowHosts are reachable in allowlist mode without configuration: the
+// Anthropic API and the package registries and downloads mise, npm, pip, Go and
+// Cargo use. A leading "*." matches any subdomain.
+var DefaultAllowHosts = []string{
+	"api.anthropic.com",
+	"statsig.anthropic.com",
+	"console.anthropic.com",
+	"claude.ai",
+	"registry.npmjs.org",
+	"pypi.org",
+	"files.pythonhosted.org",
+	"proxy.golang.org",
+	"sum.golang.org",
+	"crates.io",
+	"index.crates.io",
+	"static.crates.io",
+	"github.com",
+	"*.githubusercontent.com",
+	"mise.jdx.dev",
+	"mise-versions.jdx.dev",
+}
+
+// HostAllowed reports whether host (without port) matches an allowlist entry.
+// Entries are exact host names or "*.example.com" for any subdomain of example.com.
+func HostAllowed(host string, allow []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range allow {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// EgressProxy is an HTTP proxy on the host that only lets the container reach
+// allowlisted hosts. HTTPS goes through CONNECT tunnels, so only the host name is
+// checked; plain HTTP requests are forwarded. Denied requests are logged.
+type EgressProxy struct {
+	URL     string   // Proxy URL as seen from inside the container
+	runArgs []string // Run flags that let the container reach the proxy
+
+	allow     []string
+	log       io.Writer
+	logMu     sync.Mutex
+	denied    atomic.Int64
+	listener  net.Listener
+	server    *http.Server
+	transport *http.Transport // Shared by forwarded requests
+}
+
+// StartEgressProxy listens where gw says and serves until Close. Denied
+// requests are written to log, one line each.
+func StartEgressProxy(gw HostGateway, allow []string, log io.Writer) (*EgressProxy, error) {
+	l, addr, err := gw.listen()
+	if err != nil {
+		return nil, fmt.Errorf("starting egress proxy: %w", err)
+	}
+	p := &EgressProxy{
+		URL:      "http://" + addr,
+		runArgs:  gw.Args,
+		allow:    allow,
+		log:      log,
+		listener: l,
+		// Never chain to a proxy configured on the host
+		transport: &http.Transport{Proxy: nil, IdleConnTimeout: 90 * time.Second},
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+	go p.server.Serve(l)
+	return p, nil
+}
+
+// Addr returns the address the proxy listens on.
+func (p *EgressProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Denied returns the number of requests refused so far.
+func (p *EgressProxy) Denied() int {
+	return int(p.denied.Load())
+}
+
+// Close stops the proxy and closes open tunnels.
+func (p *EgressProxy) Close() error {
+	err := p.server.Close()
+	p.transport.CloseIdleConnections()
+	return err
+}
+
+// ServeHTTP handles CONNECT tunnels and absolute-URI HTTP requests.
+func (p *EgressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Hostname()
+	if r.Method == http.MethodConnect {
+		host, _, _ = net.SplitHostPort(r.Host)
+	}
+	if host == "" || !HostAllowed(host, p.allow) {
+		p.deny(r)
+		http.Error(w, "wt sandbox: host not in network allowlist", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+// deny records a refused request.
+func (p *EgressProxy) deny(r *http.Request) {
+	p.denied.Add(1)
+	if p.log == nil {
+		return
+	}
+	target := r.Host
+	if r.Method != http.MethodConnect {
+		target = r.URL.String()
+	}
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
+	fmt.Fprintf(p.log, "%s denied %s %s\n", time.Now().Format(time.RFC3339), r.Method, target)
+}
+
+// tunnel connects the client to the requested host:port and copies bytes both ways.
+func (p *EgressProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// Bytes the client sent after the CONNECT request are buffered
+		io.Copy(upstream, buf)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	upstream.Close()
+}
+
+// forward proxies a plain HTTP request.
+func (p *EgressProxy) forward(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
@@ -0,0 +1,160 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHostAllowed(t *testing.T) {
+	allow := []string{"api.anthropic.com", "*.githubusercontent.com"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"api.anthropic.com", true},
+		{"API.Anthropic.com.", true},
+		{"evil.anthropic.com", false},
+		{"objects.githubusercontent.com", true},
+		{"githubusercontent.com", false},
+		{"githubusercontent.com.evil.io", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if got := HostAllowed(tt.host, allow); got != tt.want {
+			t.Errorf("HostAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+// proxyClient returns an HTTP client that sends all requests through p.
+func proxyClient(t *testing.T, p *EgressProxy, base *http.Transport) *http.Client {
+	t.Helper()
+	proxyURL, err := url.Parse("http://" + p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := base.Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport}
+}
+
+func TestEgressProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer backend.Close()
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure hello")
+	}))
+	defer tlsBackend.Close()
+
+	gw := HostGateway{ListenAddr: "127.0.0.1", Host: "10.0.2.2"}
+	log := new(bytes.Buffer)
+	p, err := StartEgressProxy(gw, []string{"127.0.0.1"}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if !strings.HasPrefix(p.URL, "http://10.0.2.2:") {
+		t.Errorf("URL = %q, want the container-side gateway host", p.URL)
+	}
+
+	// Plain HTTP is forwarded
+	resp, err := proxyClient(t, p, http.DefaultTransport.(*http.Transport)).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("HTTP body = %q, want hello", body)
+	}
+
+	// HTTPS is tunneled with CONNECT
+	resp, err = proxyClient(t, p, tlsBackend.Client().Transport.(*http.Transport)).Get(tlsBackend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "secure hello" {
+		t.Errorf("HTTPS body = %q, want secure hello", body)
+	}
+
+	if p.Denied() != 0 {
+		t.Errorf("Denied() = %d, want 0", p.Denied())
+	}
+}
+
+func TestEgressProxy_DeniesUnlistedHosts(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a host that is not allowlisted")
+	}))
+	defer backend.Close()
+
+	log := new(bytes.Buffer)
+	p, err := StartEgressProxy(HostGateway{ListenAddr: "127.0.0.1", Host: "127.0.0.1"}, []string{"api.anthropic.com"}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	resp, err := proxyClient(t, p, http.DefaultTransport.(*http.Transport)).Get(backend.URL + "/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+
+	if p.Denied() != 1 {
+		t.Errorf("Denied() = %d, want 1", p.Denied())
+	}
+	if !strings.Contains(log.String(), "denied GET "+backend.URL+"/x") {
+		t.Errorf("log = %q, want the denied request", log.String())
+	}
+}
+
+func TestEgressProxy_UnixSocket(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer backend.Close()
+
+	// What an EgressNetwork's Gateway returns: the relay dials the socket
+	gw := HostGateway{Socket: filepath.Join(t.TempDir(), "3128.sock"), Host: "10.89.0.2", Port: EgressProxyPort}
+	p, err := StartEgressProxy(gw, []string{"127.0.0.1"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if p.URL != "http://10.89.0.2:3128" {
+		t.Errorf("URL = %q, want the relay's address", p.URL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return new(net.Dialer).DialContext(ctx, "unix", gw.Socket)
+	}
+	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: "relay"})
+	resp, err := (&http.Client{Transport: transport}).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want hello", body)
+	}
+}
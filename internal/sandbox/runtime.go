@@ -0,0 +1,195 @@
+package sandbox
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Runtime is a container engine that builds images and runs sandboxes.
+// Podman and Docker accept the same build and run commands except for how the
+// container user is mapped to the host user and how SELinux labels are handled.
+type Runtime interface {
+	// Name returns the runtime's command, e.g. "podman".
+	Name() string
+	// Available returns an error if the runtime is not installed or not usable.
+	Available() error
+	// UserArgs returns run flags that make files written in the container owned by the host user.
+	UserArgs() []string
+	// MountOption translates a Mount mode into a volume option; "" drops it.
+	MountOption(mode string) string
+	// HostGateway describes how a container reaches a service listening on the host.
+	HostGateway() (HostGateway, error)
+	// InternalNetworkArgs returns `network create` flags that keep an internal
+	// network from resolving names outside it.
+	InternalNetworkArgs() []string
+}
+
+// HostGateway is how a container reaches a service on the host: the service
+// listens on ListenAddr, the container connects to Host, and Args are the run
+// flags that make Host reachable. If Socket is set, the service listens on that
+// unix socket instead and the container connects to Host:Port, which a relay
+// forwards to the socket (see EgressNetwork).
+type HostGateway struct {
+	ListenAddr string
+	Host       string
+	Args       []string
+	Socket     string
+	Port       int
+}
+
+// listen opens the listener of a host service reached through gw and returns
+// it with the service's address as seen from the container.
+func (gw HostGateway) listen() (net.Listener, string, error) {
+	if gw.Socket != "" {
+		l, err := net.Listen("unix", gw.Socket)
+		if err != nil {
+			return nil, "", err
+		}
+		return l, net.JoinHostPort(gw.Host, strconv.Itoa(gw.Port)), nil
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(gw.ListenAddr, "0"))
+	if err != nil {
+		return nil, "", err
+	}
+	return l, net.JoinHostPort(gw.Host, strconv.Itoa(l.Addr().(*net.TCPAddr).Port)), nil
+}
+
+// Mount is a bind mount into the container. Mode is "ro" for read-only mounts, or
+// an SELinux relabel option: "Z" (private to this container) or "z" (shared).
+type Mount struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Mode   string `json:"mode,omitempty"`
+}
+
+// Podman runs sandboxes with rootless Podman.
+type Podman struct{}
+
+func (Podman) Name() string { return "podman" }
+
+func (Podman) Available() error {
+	if err := exec.Command("podman", "--version").Run(); err != nil {
+		return fmt.Errorf("podman not found in PATH: install Podman or use --runtime=docker")
+	}
+	return nil
+}
+
+// UserArgs keeps the host UID inside the user namespace.
+func (Podman) UserArgs() []string { return []string{"--userns=keep-id"} }
+
+// MountOption passes modes through; Podman ignores relabeling when SELinux is disabled.
+func (Podman) MountOption(mode string) string { return mode }
+
+// HostGateway uses slirp4netns, which maps 10.0.2.2 to the host's loopback, so
+// host services need not listen on an external interface.
+func (Podman) HostGateway() (HostGateway, error) {
+	return HostGateway{
+		ListenAddr: "127.0.0.1",
+		Host:       "10.0.2.2",
+		Args:       []string{"--network=slirp4netns:allow_host_loopback=true"},
+	}, nil
+}
+
+// InternalNetworkArgs disables aardvark-dns, which would forward queries for
+// outside names to the host's resolvers.
+func (Podman) InternalNetworkArgs() []string { return []string{"--disable-dns"} }
+
+// Docker runs sandboxes with the Docker daemon.
+type Docker struct {
+	// Rootless is set for rootless Docker, where container root is the host user.
+	Rootless bool
+}
+
+// NewDocker returns a Docker runtime, detecting whether the daemon runs rootless.
+func NewDocker() Docker {
+	out, err := exec.Command("docker", "info", "--format", "{{json .SecurityOptions}}").Output()
+	return Docker{Rootless: err == nil && strings.Contains(string(out), "rootless")}
+}
+
+func (Docker) Name() string { return "docker" }
+
+func (Docker) Available() error {
+	if err := exec.Command("docker", "version", "--format", "{{.Server.Version}}").Run(); err != nil {
+		return fmt.Errorf("docker not available: install Docker and start the daemon, or use --runtime=podman")
+	}
+	return nil
+}
+
+// UserArgs runs as the host UID/GID; rootless Docker already maps root to the host user.
+func (d Docker) UserArgs() []string {
+	if d.Rootless {
+		return nil
+	}
+	return []string{"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())}
+}
+
+// MountOption drops SELinux relabel options unless SELinux is enabled, where
+// Docker needs them just like Podman.
+func (Docker) MountOption(mode string) string {
+	if (mode == "Z" || mode == "z") && !selinuxEnabled() {
+		return ""
+	}
+	return mode
+}
+
+// HostGateway listens on the default bridge's gateway address, which the
+// container reaches as host.docker.internal.
+func (Docker) HostGateway() (HostGateway, error) {
+	out, err := exec.Command("docker", "network", "inspect", "bridge", "--format", "{{range .IPAM.Config}}{{.Gateway}} {{end}}").Output()
+	if err != nil {
+		return HostGateway{}, fmt.Errorf("docker network inspect bridge: %w", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return HostGateway{}, fmt.Errorf("docker bridge network has no gateway address")
+	}
+	return HostGateway{
+		ListenAddr: fields[0],
+		Host:       "host.docker.internal",
+		Args:       []string{"--add-host=host.docker.internal:host-gateway"},
+	}, nil
+}
+
+// InternalNetworkArgs returns no flags: Docker's embedded DNS does not forward
+// queries from internal networks.
+func (Docker) InternalNetworkArgs() []string { return nil }
+
+// selinuxEnabled reports whether the host has SELinux enabled.
+func selinuxEnabled() bool {
+	_, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil
+}
+
+// DetectRuntime returns the runtime called name ("podman" or "docker"), or for
+// "" or "auto" the first one installed, preferring Podman.
+func DetectRuntime(name string) (Runtime, error) {
+	switch name {
+	case "podman":
+		return Podman{}, nil
+	case "docker":
+		return NewDocker(), nil
+	case "", "auto":
+		if _, err := exec.LookPath("podman"); err == nil {
+			return Podman{}, nil
+		}
+		if _, err := exec.LookPath("docker"); err == nil {
+			return NewDocker(), nil
+		}
+		return nil, fmt.Errorf("no container runtime found: install Podman or Docker")
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q (want podman, docker or auto)", name)
+	}
+}
+
+// volumeArgs formats a mount as -v arguments for rt.
+func volumeArgs(rt Runtime, m Mount) []string {
+	spec := m.Source + ":" + m.Target
+	if opt := rt.MountOption(m.Mode); opt != "" {
+		spec += ":" + opt
+	}
+	return []string{"-v", spec}
+}
@@ -0,0 +1,61 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDetectRuntime(t *testing.T) {
+	rt, err := DetectRuntime("podman")
+	if err != nil || rt.Name() != "podman" {
+		t.Errorf("DetectRuntime(podman) = %v, %v", rt, err)
+	}
+	rt, err = DetectRuntime("docker")
+	if err != nil || rt.Name() != "docker" {
+		t.Errorf("DetectRuntime(docker) = %v, %v", rt, err)
+	}
+	if _, err := DetectRuntime("lxc"); err == nil {
+		t.Error("DetectRuntime(lxc) should fail")
+	}
+}
+
+func TestBuildArgsDocker(t *testing.T) {
+	opts := &Options{
+		WorktreePath:   "/tmp/test-worktree",
+		MainGitDir:     "/tmp/repo/.git",
+		ContainerImage: "wt-sandbox",
+		Runtime:        Docker{},
+	}
+
+	args, err := opts.BuildArgs()
+	if err != nil {
+		t.Fatalf("BuildArgs failed: %v", err)
+	}
+	argStr := strings.Join(args, " ")
+
+	if strings.Contains(argStr, "--userns=keep-id") {
+		t.Errorf("docker does not support --userns=keep-id, got: %s", argStr)
+	}
+	if want := fmt.Sprintf("--user %d:%d", os.Getuid(), os.Getgid()); !strings.Contains(argStr, want) {
+		t.Errorf("missing %q, got: %s", want, argStr)
+	}
+
+	worktreeMount := "-v /tmp/test-worktree:/tmp/test-worktree"
+	if selinuxEnabled() {
+		worktreeMount += ":Z"
+	}
+	if !strings.Contains(argStr, worktreeMount+" ") {
+		t.Errorf("missing %q, got: %s", worktreeMount, argStr)
+	}
+	if !strings.Contains(argStr, "-v /tmp/repo/.git:/tmp/repo/.git:ro") {
+		t.Errorf("read-only mount must keep :ro, got: %s", argStr)
+	}
+}
+
+func TestDockerRootlessUserArgs(t *testing.T) {
+	if args := (Docker{Rootless: true}).UserArgs(); len(args) != 0 {
+		t.Errorf("rootless docker UserArgs() = %v, want none", args)
+	}
+}
@@ -5,73 +5,247 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 )
 
 // Options configures the sandbox container
 type Options struct {
-	WorktreePath     string
-	MainGitDir       string
-	ClaudeDir        string
-	ClaudeConfigFile string // ~/.claude.json global state file
-	MiseDataDir      string
-	MiseStateDir     string
-	MiseCacheDir     string
-	ExtraMounts      []string
-	ContainerImage   string
-	RunMiseInstall   bool
-	StartClaude      bool
+	WorktreePath      string
+	MainGitDir        string
+	GitIsolation      *GitIsolation // If set, replaces the read-only MainGitDir mount
+	ClaudeDir         string
+	ClaudeConfigFile  string // ~/.claude.json global state file
+	MiseDataDir       string
+	MiseStateDir      string
+	MiseCacheDir      string
+	ExtraMounts       []string
+	Mounts            []Mount           // Mounts at their own target paths, e.g. from devcontainer.json
+	Env               map[string]string // Environment variables set in the container
+	EnvFiles          []string          // Files of environment variables, e.g. from WriteEnvFile
+	Ports             []string          // Published ports, as for --publish
+	RunArgs           []string          // Extra run arguments, added last
+	ContainerImage    string
+	Runtime           Runtime      // Container engine; nil means Podman
+	Network           string       // NetworkFull (default), NetworkNone or NetworkAllowlist
+	Egress            *EgressProxy // Proxy for NetworkAllowlist
+	GitBridge         *GitBridge   // Fetch and push with the host's git credentials
+	SSHAgent          string       // Host SSH agent socket to forward, e.g. $SSH_AUTH_SOCK
+	Limits            Limits       // Resource limits
+	Name              string       // Container name; required for Detach
+	Labels            map[string]string
+	Detach            bool   // Run in the background and keep the container after it exits
+	Prompt            string // Run Claude headless (print mode) with this prompt instead of interactively
+	PromptPath        string // Host file holding Prompt, mounted read-only; set by RunHeadless
+	PostCreateCommand string // Shell command run before the agent starts
+	RunMiseInstall    bool
+	StartClaude       bool
 }
 
-// CheckPodmanAvailable verifies podman is installed
-func CheckPodmanAvailable() error {
-	cmd := exec.Command("podman", "--version")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("podman not found. Install with: sudo pacman -S podman")
+// Network policies for the sandbox container.
+const (
+	NetworkFull      = "full"      // Unrestricted network access
+	NetworkNone      = "none"      // No network at all
+	NetworkAllowlist = "allowlist" // Only allowlisted hosts, through an EgressProxy
+)
+
+// ParseNetwork validates a network policy name; "" means NetworkFull.
+func ParseNetwork(name string) (string, error) {
+	switch name {
+	case "":
+		return NetworkFull, nil
+	case NetworkFull, NetworkNone, NetworkAllowlist:
+		return name, nil
+	default:
+		return "", fmt.Errorf("invalid network policy %q (want none, allowlist or full)", name)
+	}
+}
+
+// sshAgentTarget is where a forwarded SSH agent socket is mounted.
+const sshAgentTarget = "/run/wt/ssh-agent.sock"
+
+// postCreateMarker records in the container that PostCreateCommand has run.
+const postCreateMarker = "/var/tmp/wt-post-create-done"
+
+// promptTarget is where the prompt of a headless run is mounted.
+const promptTarget = "/run/wt/prompt"
+
+// runtime returns the configured runtime, defaulting to Podman.
+func (o *Options) runtime() Runtime {
+	if o.Runtime == nil {
+		return Podman{}
 	}
-	return nil
+	return o.Runtime
 }
 
-// BuildArgs constructs podman run arguments
+// BuildArgs constructs container run arguments for the configured runtime
 func (o *Options) BuildArgs() ([]string, error) {
-	args := []string{
-		"run",
-		"--rm",
-		"-it",
-		"--userns=keep-id",
-		"--dns=8.8.8.8",
+	rt := o.runtime()
+	args := []string{"run"}
+	if o.Detach {
+		if o.Name == "" {
+			return nil, fmt.Errorf("a detached sandbox needs a container name")
+		}
+		args = append(args, "-d", "-it")
+	} else if o.Prompt != "" {
+		// Headless: output is captured, so no TTY
+		args = append(args, "--rm")
+	} else {
+		args = append(args, "--rm", "-it")
+	}
+	if o.Name != "" {
+		args = append(args, "--name", o.Name)
+	}
+	labels := make([]string, 0, len(o.Labels))
+	for k, v := range o.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		args = append(args, "--label", label)
+	}
+	args = append(args, rt.UserArgs()...)
+
+	switch o.Network {
+	case "", NetworkFull:
+		args = append(args, "--dns=8.8.8.8")
+	case NetworkNone:
+		args = append(args, "--network=none")
+	case NetworkAllowlist:
+		if o.Egress == nil {
+			return nil, fmt.Errorf("network policy %q needs an egress proxy", o.Network)
+		}
+		args = append(args, o.Egress.runArgs...)
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			args = append(args, "-e", name+"="+o.Egress.URL)
+		}
+		noProxy := "localhost,127.0.0.1"
+		if o.GitBridge != nil {
+			// The bridge is on the host; the proxy would refuse it
+			noProxy += "," + o.GitBridge.Host
+		}
+		args = append(args, "-e", "NO_PROXY="+noProxy, "-e", "no_proxy="+noProxy)
+	default:
+		return nil, fmt.Errorf("invalid network policy %q", o.Network)
+	}
+
+	if o.GitBridge != nil {
+		if o.Network == NetworkNone {
+			return nil, fmt.Errorf("the git bridge needs network access")
+		}
+		for _, arg := range o.GitBridge.runArgs {
+			// The egress proxy may have added the same gateway flags
+			if !slices.Contains(args, arg) {
+				args = append(args, arg)
+			}
+		}
+	}
+
+	if o.Prompt != "" && o.PromptPath == "" {
+		// Kept out of the command line, where any process could read it
+		return nil, fmt.Errorf("headless run needs its prompt in a file")
+	}
+
+	env := make([]string, 0, len(o.Env))
+	for k, v := range o.Env {
+		env = append(env, k+"="+v)
+	}
+	if o.GitBridge != nil {
+		for k, v := range o.GitBridge.Env() {
+			env = append(env, k+"="+v)
+		}
+	}
+	if o.SSHAgent != "" {
+		env = append(env, "SSH_AUTH_SOCK="+sshAgentTarget)
+	}
+	sort.Strings(env)
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	for _, file := range o.EnvFiles {
+		args = append(args, "--env-file", file)
+	}
+	for _, port := range o.Ports {
+		args = append(args, "-p", port)
+	}
+
+	if err := o.Limits.Validate(); err != nil {
+		return nil, err
+	}
+	args = append(args, o.Limits.Args()...)
+
+	// Set HOME to parent of ClaudeDir so Claude Code finds its config
+	if o.ClaudeDir != "" {
+		args = append(args, "-e", fmt.Sprintf("HOME=%s", filepath.Dir(o.ClaudeDir)))
+	}
+
+	mounts, err := o.AllMounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mounts {
+		args = append(args, volumeArgs(rt, m)...)
+	}
+
+	args = append(args, o.RunArgs...)
+
+	// Working directory
+	args = append(args, "-w", o.WorktreePath)
+
+	// Image
+	args = append(args, o.ContainerImage)
+
+	return args, nil
+}
+
+// AllMounts returns every bind mount of the container, in the order they are
+// passed to the runtime.
+func (o *Options) AllMounts() ([]Mount, error) {
+	var mounts []Mount
+	addMount := func(source, target, mode string) {
+		mounts = append(mounts, Mount{Source: source, Target: target, Mode: mode})
 	}
 
 	// Mount worktree at same path
-	args = append(args, "-v", fmt.Sprintf("%s:%s:Z", o.WorktreePath, o.WorktreePath))
+	addMount(o.WorktreePath, o.WorktreePath, "Z")
 
-	// Mount main git dir read-only
-	if o.MainGitDir != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", o.MainGitDir, o.MainGitDir))
+	// Mount a private copy of the main git dir so commits work, or the real one read-only
+	if o.GitIsolation != nil {
+		mounts = append(mounts, o.GitIsolation.Mounts()...)
+	} else if o.MainGitDir != "" {
+		addMount(o.MainGitDir, o.MainGitDir, "ro")
 	}
 
 	// Mount claude dir read-write (Claude Code needs to write debug logs, history, etc.)
 	if o.ClaudeDir != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s:Z", o.ClaudeDir, o.ClaudeDir))
-		// Set HOME to parent of ClaudeDir so Claude Code finds its config
-		homeDir := filepath.Dir(o.ClaudeDir)
-		args = append(args, "-e", fmt.Sprintf("HOME=%s", homeDir))
+		addMount(o.ClaudeDir, o.ClaudeDir, "Z")
 	}
 
 	// Mount claude global config file (~/.claude.json) read-write
 	if o.ClaudeConfigFile != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s:Z", o.ClaudeConfigFile, o.ClaudeConfigFile))
+		addMount(o.ClaudeConfigFile, o.ClaudeConfigFile, "Z")
 	}
 
 	// Mount mise directories read-write so tools and state persist
 	if o.MiseDataDir != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s:Z", o.MiseDataDir, o.MiseDataDir))
+		addMount(o.MiseDataDir, o.MiseDataDir, "Z")
 	}
 	if o.MiseStateDir != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s:Z", o.MiseStateDir, o.MiseStateDir))
+		addMount(o.MiseStateDir, o.MiseStateDir, "Z")
 	}
 	if o.MiseCacheDir != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s:Z", o.MiseCacheDir, o.MiseCacheDir))
+		addMount(o.MiseCacheDir, o.MiseCacheDir, "Z")
+	}
+
+	// Forwarded SSH agent, at a fixed path: the host's socket directory may not exist in the container
+	if o.SSHAgent != "" {
+		addMount(o.SSHAgent, sshAgentTarget, "z")
+	}
+
+	// Prompt of a headless run, read by Claude from stdin
+	if o.PromptPath != "" {
+		addMount(o.PromptPath, promptTarget, "ro")
 	}
 
 	// Extra mounts
@@ -90,30 +264,33 @@ func (o *Options) BuildArgs() ([]string, error) {
 			}
 			path = filepath.Join(home, path[2:])
 		}
-		args = append(args, "-v", fmt.Sprintf("%s:%s:%s", path, path, mode))
+		addMount(path, path, mode)
 	}
-
-	// Working directory
-	args = append(args, "-w", o.WorktreePath)
-
-	// Image
-	args = append(args, o.ContainerImage)
-
-	return args, nil
+	return append(mounts, o.Mounts...), nil
 }
 
-// BuildImage builds the sandbox container image
-func BuildImage(containerfilePath, imageName string) error {
-	cmd := exec.Command("podman", "build", "-t", imageName, "-f", containerfilePath, ".")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
-// ImageExists checks if an image exists locally
-func ImageExists(imageName string) bool {
-	cmd := exec.Command("podman", "image", "exists", imageName)
-	return cmd.Run() == nil
+// innerCommand returns the shell command run inside the container.
+func (o *Options) innerCommand() string {
+	var steps []string
+	if o.PostCreateCommand != "" {
+		// Once per container: a persistent one runs this again when restarted
+		steps = append(steps, fmt.Sprintf("if [ ! -e %[1]s ]; then (%[2]s) && touch %[1]s; fi", postCreateMarker, o.PostCreateCommand))
+	}
+	if o.RunMiseInstall {
+		steps = append(steps, "mise install")
+	}
+	if o.Prompt != "" {
+		// Keep setup output out of the JSON transcript on stdout
+		for i := range steps {
+			steps[i] += " >&2"
+		}
+		steps = append(steps, "claude --dangerously-skip-permissions -p --output-format stream-json --verbose < "+promptTarget)
+	} else if o.StartClaude {
+		steps = append(steps, "claude --dangerously-skip-permissions")
+	} else {
+		steps = append(steps, "bash")
+	}
+	return strings.Join(steps, " && ")
 }
 
 // Run starts the sandbox container
@@ -123,21 +300,9 @@ func Run(opts *Options) error {
 		return err
 	}
 
-	// Build the command to run inside
-	var innerCmd string
-	if opts.RunMiseInstall && opts.StartClaude {
-		innerCmd = "mise install && claude --dangerously-skip-permissions"
-	} else if opts.RunMiseInstall {
-		innerCmd = "mise install && bash"
-	} else if opts.StartClaude {
-		innerCmd = "claude --dangerously-skip-permissions"
-	} else {
-		innerCmd = "bash"
-	}
-
-	args = append(args, "bash", "-c", innerCmd)
+	args = append(args, "bash", "-c", opts.innerCommand())
 
-	cmd := exec.Command("podman", args...)
+	cmd := exec.Command(opts.runtime().Name(), args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -16,7 +16,7 @@ const bashInit = `# wt shell integration
 
 wt() {
     case "$1" in
-        switch)
+        new|switch)
             # Check if this might be interactive (switch with no branch argument)
             # Interactive mode needs direct TTY access, so we can't use command substitution
             if [ "$1" = "switch" ] && [ $# -eq 1 ]; then
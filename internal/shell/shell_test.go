@@ -29,6 +29,15 @@ func TestGenerateBashInit(t *testing.T) {
 	}
 }
 
+func TestGenerateBashInit_WrapsNew(t *testing.T) {
+	script := GenerateInit("bash")
+
+	// wt new prints the created path like switch, so the wrapper must cd for it too
+	if !strings.Contains(script, "new|switch)") {
+		t.Error("script should handle new command with cd")
+	}
+}
+
 func TestGenerateZshInit(t *testing.T) {
 	script := GenerateInit("zsh")
 
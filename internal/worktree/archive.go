@@ -0,0 +1,272 @@
+package worktree
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niref/wt/internal/gitcmd"
+)
+
+// ArchiveRefPrefix is the ref namespace holding archives of removed worktrees.
+// Each archive is refs/wt/archive/<name>/<unix-time>, with names git does not
+// accept in a ref hex-encoded (see archiveRefName).
+const ArchiveRefPrefix = "refs/wt/archive/"
+
+// maxArchiveIDProbes bounds the search for a free archive ID.
+const maxArchiveIDProbes = 100
+
+// ErrArchiveNotFound is returned when a worktree has no matching archive.
+var ErrArchiveNotFound = errors.New("archive not found")
+
+// Archive describes the saved state of a removed worktree.
+//
+// The archive ref points at a stash-like snapshot commit whose tree holds the
+// worktree contents including uncommitted and untracked (but not ignored) files.
+// Its parent is the branch tip at removal time, so the ref also keeps unpushed
+// commits reachable after the branch is deleted.
+type Archive struct {
+	Name    string    `json:"name"`
+	ID      string    `json:"id"`
+	Ref     string    `json:"ref"`
+	Commit  string    `json:"commit"`
+	Branch  string    `json:"branch"`
+	Head    string    `json:"head"`
+	Dirty   bool      `json:"dirty"`
+	Created time.Time `json:"created"`
+}
+
+// Archive trailers recorded in the snapshot commit message.
+const (
+	archiveNameTrailer   = "wt-name: "
+	archiveBranchTrailer = "wt-branch: "
+	archiveHeadTrailer   = "wt-head: "
+	archiveDirtyTrailer  = "wt-dirty: "
+)
+
+// Archive saves the state of worktree name under refs/wt/archive/<name>/ and
+// returns the new archive.
+func (m *Manager) Archive(name string) (Archive, error) {
+	wtPath := m.WorktreePath(name)
+	if !m.Exists(name) {
+		return Archive{}, ErrWorktreeNotFound
+	}
+
+	branch := branchForWorktree(wtPath)
+	head, _ := gitcmd.Output(wtPath, nil, "rev-parse", "--verify", "-q", "HEAD")
+
+	env, cleanup, err := gitcmd.SnapshotIndex(wtPath)
+	if err != nil {
+		return Archive{}, err
+	}
+	defer cleanup()
+	tree, err := gitcmd.Output(wtPath, env, "write-tree")
+	if err != nil {
+		return Archive{}, err
+	}
+
+	dirty := true
+	if head != "" {
+		headTree, err := gitcmd.Output(wtPath, nil, "rev-parse", head+"^{tree}")
+		if err != nil {
+			return Archive{}, err
+		}
+		dirty = tree != headTree
+	}
+
+	msg := fmt.Sprintf("wt archive of %s\n\n%s%s\n%s%s\n%s%s\n%s%t\n",
+		name, archiveNameTrailer, name, archiveBranchTrailer, branch, archiveHeadTrailer, head, archiveDirtyTrailer, dirty)
+	args := []string{"commit-tree", tree, "-m", msg}
+	if head != "" {
+		args = append(args, "-p", head)
+	}
+	commit, err := gitcmd.Output(m.RepoRoot, archiveIdentity(m.RepoRoot), args...)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	// IDs are Unix times; an archive of the same name made within the same
+	// second takes the next free one. The empty old value makes update-ref
+	// fail rather than overwrite an existing archive.
+	now := time.Now()
+	refName := archiveRefName(m.RepoRoot, name)
+	var id, ref string
+	for seq := int64(0); ; seq++ {
+		id = strconv.FormatInt(now.Unix()+seq, 10)
+		ref = ArchiveRefPrefix + refName + "/" + id
+		_, err := gitcmd.Output(m.RepoRoot, nil, "update-ref", "-m", "wt archive", ref, commit, "")
+		if err == nil {
+			break
+		}
+		if _, exists := gitcmd.Output(m.RepoRoot, nil, "rev-parse", "--verify", "-q", ref); exists != nil || seq == maxArchiveIDProbes {
+			return Archive{}, err
+		}
+	}
+
+	return Archive{
+		Name:    name,
+		ID:      id,
+		Ref:     ref,
+		Commit:  commit,
+		Branch:  branch,
+		Head:    head,
+		Dirty:   dirty,
+		Created: time.Unix(now.Unix(), 0),
+	}, nil
+}
+
+// archiveRefName returns the ref path component for the archives of worktree
+// name: the name itself if git accepts it in a ref, otherwise "_" and its hex
+// encoding, so an unusual name cannot block a removal. The real name is kept in
+// the archive's wt-name trailer.
+func archiveRefName(dir, name string) string {
+	if _, err := gitcmd.Output(dir, nil, "check-ref-format", ArchiveRefPrefix+name+"/0"); err == nil {
+		return name
+	}
+	return "_" + hex.EncodeToString([]byte(name))
+}
+
+// archiveIdentity returns environment overrides for commit-tree when no git
+// identity is configured, so archiving never blocks a removal.
+func archiveIdentity(dir string) []string {
+	if _, err := gitcmd.Output(dir, nil, "var", "GIT_COMMITTER_IDENT"); err == nil {
+		if _, err := gitcmd.Output(dir, nil, "var", "GIT_AUTHOR_IDENT"); err == nil {
+			return nil
+		}
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=wt", "GIT_AUTHOR_EMAIL=wt@localhost",
+		"GIT_COMMITTER_NAME=wt", "GIT_COMMITTER_EMAIL=wt@localhost",
+	}
+}
+
+// Archives lists all archives, newest first.
+func (m *Manager) Archives() ([]Archive, error) {
+	out, err := gitcmd.Output(m.RepoRoot, nil, "for-each-ref",
+		"--format=%(refname)%00%(objectname)%00%(creatordate:unix)%00%(contents:body)%00",
+		ArchiveRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+	archives := parseArchives(out)
+	sort.SliceStable(archives, func(i, j int) bool {
+		if !archives[i].Created.Equal(archives[j].Created) {
+			return archives[i].Created.After(archives[j].Created)
+		}
+		if archives[i].Name != archives[j].Name {
+			return archives[i].Name < archives[j].Name
+		}
+		// Archived within the same second: higher IDs are newer
+		return archiveIDAfter(archives[i].ID, archives[j].ID)
+	})
+	return archives, nil
+}
+
+// archiveIDAfter reports whether archive ID a is newer than b.
+func archiveIDAfter(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+// parseArchives parses the NUL-separated for-each-ref output of Archives.
+func parseArchives(out string) []Archive {
+	fields := strings.Split(out, "\x00")
+	var archives []Archive
+	for i := 0; i+3 < len(fields); i += 4 {
+		ref := strings.TrimSpace(fields[i])
+		rest, ok := strings.CutPrefix(ref, ArchiveRefPrefix)
+		if !ok {
+			continue
+		}
+		slash := strings.LastIndex(rest, "/")
+		if slash < 0 {
+			continue
+		}
+		a := Archive{
+			Name:   rest[:slash],
+			ID:     rest[slash+1:],
+			Ref:    ref,
+			Commit: fields[i+1],
+		}
+		if sec, err := strconv.ParseInt(fields[i+2], 10, 64); err == nil {
+			a.Created = time.Unix(sec, 0)
+		}
+		for _, line := range strings.Split(fields[i+3], "\n") {
+			if v, ok := strings.CutPrefix(line, archiveNameTrailer); ok {
+				a.Name = v
+			} else if v, ok := strings.CutPrefix(line, archiveBranchTrailer); ok {
+				a.Branch = v
+			} else if v, ok := strings.CutPrefix(line, archiveHeadTrailer); ok {
+				a.Head = v
+			} else if v, ok := strings.CutPrefix(line, archiveDirtyTrailer); ok {
+				a.Dirty = v == "true"
+			}
+		}
+		archives = append(archives, a)
+	}
+	return archives
+}
+
+// FindArchive returns the archive of name with the given ID, or the newest
+// archive of name if id is empty.
+func (m *Manager) FindArchive(name, id string) (Archive, error) {
+	archives, err := m.Archives()
+	if err != nil {
+		return Archive{}, err
+	}
+	for _, a := range archives {
+		if a.Name == name && (id == "" || a.ID == id) {
+			return a, nil
+		}
+	}
+	return Archive{}, ErrArchiveNotFound
+}
+
+// Restore recreates a removed worktree from an archive: the branch is recreated
+// at its archived tip (or reused if it still points there) and uncommitted and
+// untracked files are written back as unstaged changes. The archive ref is deleted
+// once the worktree is restored.
+func (m *Manager) Restore(a Archive) error {
+	if m.Exists(a.Name) {
+		return fmt.Errorf("worktree %q already exists", a.Name)
+	}
+	if a.Head == "" {
+		return fmt.Errorf("archive %s has no commit to restore", a.Ref)
+	}
+
+	wtPath := m.WorktreePath(a.Name)
+	var args []string
+	switch {
+	case a.Branch == "" || a.Branch == "HEAD":
+		args = []string{"worktree", "add", "--detach", wtPath, a.Head}
+	case m.BranchExists(a.Branch):
+		tip, err := gitcmd.Output(m.RepoRoot, nil, "rev-parse", "refs/heads/"+a.Branch)
+		if err != nil {
+			return err
+		}
+		if tip != a.Head {
+			return fmt.Errorf("branch %q has moved since it was archived; delete or rename it first", a.Branch)
+		}
+		args = []string{"worktree", "add", wtPath, a.Branch}
+	default:
+		args = []string{"worktree", "add", "-b", a.Branch, wtPath, a.Head}
+	}
+	if _, err := gitcmd.Output(m.RepoRoot, nil, args...); err != nil {
+		return fmt.Errorf("restoring worktree %q: %w", a.Name, err)
+	}
+
+	if a.Dirty {
+		if _, err := gitcmd.Output(wtPath, nil, "restore", "--source="+a.Commit, "--worktree", "--", "."); err != nil {
+			return fmt.Errorf("restoring uncommitted changes of %q: %w", a.Name, err)
+		}
+	}
+
+	_, err := gitcmd.Output(m.RepoRoot, nil, "update-ref", "-d", a.Ref, a.Commit)
+	return err
+}
@@ -0,0 +1,179 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveAndRestore(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	wtPath := createWorktreeInRepo(t, mainRepo, "feature", "feature")
+	commitFile(t, wtPath, "committed.txt", "committed")
+	if err := os.WriteFile(filepath.Join(wtPath, "committed.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wtPath, "untracked.txt"), []byte("untracked"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.Remove("feature", true); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if mgr.BranchExists("feature") {
+		t.Fatal("branch should be deleted by Remove")
+	}
+
+	archives, err := mgr.Archives()
+	if err != nil {
+		t.Fatalf("Archives() error = %v", err)
+	}
+	if len(archives) != 1 {
+		t.Fatalf("Archives() returned %d archives, want 1", len(archives))
+	}
+	a := archives[0]
+	if a.Name != "feature" || a.Branch != "feature" || !a.Dirty || a.Head == "" || a.Created.IsZero() {
+		t.Errorf("Archives()[0] = %+v", a)
+	}
+
+	found, err := mgr.FindArchive("feature", "")
+	if err != nil || found.Ref != a.Ref {
+		t.Fatalf("FindArchive() = %+v, %v", found, err)
+	}
+	if err := mgr.Restore(found); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	for file, want := range map[string]string{"committed.txt": "modified", "untracked.txt": "untracked"} {
+		got, err := os.ReadFile(filepath.Join(wtPath, file))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", file, got, err, want)
+		}
+	}
+	if got := branchForWorktree(wtPath); got != "feature" {
+		t.Errorf("restored branch = %q, want feature", got)
+	}
+	if n := mgr.UnpublishedCount("feature"); n != 1 {
+		t.Errorf("UnpublishedCount() = %d, want the archived commit", n)
+	}
+
+	archives, err = mgr.Archives()
+	if err != nil || len(archives) != 0 {
+		t.Errorf("Archives() after restore = %+v, %v; want none", archives, err)
+	}
+}
+
+func TestArchive_SameSecond(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+	createWorktreeInRepo(t, mainRepo, "feature", "feature")
+
+	first, err := mgr.Archive("feature")
+	if err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+	second, err := mgr.Archive("feature")
+	if err != nil {
+		t.Fatalf("second Archive() error = %v", err)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("both archives got ID %s", first.ID)
+	}
+
+	found, err := mgr.FindArchive("feature", "")
+	if err != nil || found.Ref != second.Ref {
+		t.Errorf("FindArchive() = %+v, %v; want the newer archive %s", found, err, second.Ref)
+	}
+}
+
+func TestRestore_KeptBranch(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	wtPath := createWorktreeInRepo(t, mainRepo, "kept", "kept")
+	commitFile(t, wtPath, "a.txt", "a")
+	if err := mgr.RemoveWithOptions("kept", RemoveOptions{Force: true, KeepBranch: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := mgr.FindArchive("kept", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Dirty {
+		t.Error("clean worktree archived as dirty")
+	}
+
+	// Branch moved after archiving: refuse to restore onto it
+	runGit(t, mainRepo, []string{"branch", "-f", "kept", "HEAD"})
+	if err := mgr.Restore(a); err == nil {
+		t.Fatal("Restore() should fail when the branch moved")
+	}
+
+	runGit(t, mainRepo, []string{"branch", "-f", "kept", a.Head})
+	if err := mgr.Restore(a); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if !mgr.Exists("kept") {
+		t.Error("worktree not restored")
+	}
+}
+
+func TestArchive_InvalidRefName(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	// Not valid in a ref; archiving must not block the removal
+	name := "my feature.lock"
+	createWorktreeInRepo(t, mainRepo, name, "my-feature")
+	if err := mgr.Remove(name, true); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	a, err := mgr.FindArchive(name, "")
+	if err != nil {
+		t.Fatalf("FindArchive() error = %v", err)
+	}
+	if want := ArchiveRefPrefix + "_6d7920666561747572652e6c6f636b/" + a.ID; a.Ref != want {
+		t.Errorf("archive ref = %q, want %q", a.Ref, want)
+	}
+	if err := mgr.Restore(a); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if !mgr.Exists(name) {
+		t.Error("worktree not restored")
+	}
+}
+
+func TestFindArchive_NotFound(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	if _, err := mgr.FindArchive("nope", ""); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("FindArchive() error = %v, want ErrArchiveNotFound", err)
+	}
+}
+
+func TestParseArchives(t *testing.T) {
+	out := "refs/wt/archive/feat/1700000000\x00abc\x001700000000\x00wt-branch: worktree-feat\nwt-head: def\nwt-dirty: true\n\x00\n" +
+		"refs/wt/archive/nested/name/1700000100\x00123\x001700000100\x00wt-branch: HEAD\nwt-head: 456\nwt-dirty: false\n\x00\n" +
+		"refs/wt/archive/_6120622e2e63/1700000200\x00789\x001700000200\x00wt-name: a b..c\nwt-branch: abc\nwt-head: 012\nwt-dirty: false\n\x00\n"
+
+	got := parseArchives(out)
+	if len(got) != 3 {
+		t.Fatalf("parseArchives() returned %d archives, want 3", len(got))
+	}
+	if got[0].Name != "feat" || got[0].ID != "1700000000" || got[0].Commit != "abc" ||
+		got[0].Branch != "worktree-feat" || got[0].Head != "def" || !got[0].Dirty || got[0].Created.Unix() != 1700000000 {
+		t.Errorf("parseArchives()[0] = %+v", got[0])
+	}
+	if got[1].Name != "nested/name" || got[1].Branch != "HEAD" || got[1].Dirty {
+		t.Errorf("parseArchives()[1] = %+v", got[1])
+	}
+	if got[2].Name != "a b..c" || got[2].ID != "1700000200" || got[2].Branch != "abc" {
+		t.Errorf("parseArchives()[2] = %+v", got[2])
+	}
+}
@@ -0,0 +1,152 @@
+package worktree
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MergedReason reports whether the changes on branch are already in base and how
+// they got there. It tries, in order:
+//
+//   - ancestry: branch is an ancestor of base (fast-forward or merge commit)
+//   - patch-id equivalence: every commit on branch has an equivalent patch in
+//     base according to `git cherry` (rebase or cherry-pick merges)
+//   - tree comparison: merging branch into base would not change base's tree
+//     (squash merges, where no single commit in base matches)
+//
+// A branch that is an ancestor of base is only reported as merged if its
+// reflog shows it moved since it was created; a freshly created worktree's
+// branch is also an ancestor of base. Without a usable reflog (expired,
+// core.logAllRefUpdates=false, or a branch made with update-ref) that is
+// unknown, so the branch is kept.
+//
+// Squash merges are only detected with git 2.38 or newer (see
+// SquashDetectionSupported).
+func (m *Manager) MergedReason(branch, base string) (string, bool) {
+	if m.isAncestor(branch, base) {
+		if !m.movedSinceCreation(branch) {
+			return "", false
+		}
+		return "merged into " + base, true
+	}
+	if m.allPatchesIn(branch, base) {
+		return "rebase-merged into " + base, true
+	}
+	if m.mergeLeavesTreeUnchanged(branch, base) {
+		return "squash-merged into " + base, true
+	}
+	return "", false
+}
+
+// isAncestor reports whether rev is an ancestor of (or equal to) base.
+func (m *Manager) isAncestor(rev, base string) bool {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", rev, base)
+	cmd.Dir = m.RepoRoot
+	return cmd.Run() == nil
+}
+
+// movedSinceCreation reports whether the reflog of branch shows that its tip
+// differs from the commit it was created at. It returns false if the reflog
+// can't be read or is empty.
+func (m *Manager) movedSinceCreation(branch string) bool {
+	cmd := exec.Command("git", "reflog", "show", "--format=%H", "refs/heads/"+branch, "--")
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	entries := strings.Fields(string(out))
+	if len(entries) == 0 {
+		return false
+	}
+	// Newest entry first; the oldest is the creation
+	return entries[0] != entries[len(entries)-1]
+}
+
+// SquashDetectionSupported reports whether git is new enough (2.38, for
+// merge-tree --write-tree) for MergedReason to detect squash merges.
+func SquashDetectionSupported() bool {
+	return gitVersionAtLeast(2, 38)
+}
+
+// gitVersion caches the major and minor version of the git binary, or 0, 0 if
+// it can't be determined.
+var gitVersion = sync.OnceValues(func() (int, int) {
+	out, err := exec.Command("git", "version").Output()
+	if err != nil {
+		return 0, 0
+	}
+	return parseGitVersion(string(out))
+})
+
+// parseGitVersion extracts the major and minor version from `git version`
+// output such as "git version 2.39.5" or "git version 2.37.1 (Apple Git-137.1)".
+func parseGitVersion(out string) (int, int) {
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return 0, 0
+	}
+	parts := strings.SplitN(fields[2], ".", 3)
+	if len(parts) < 2 {
+		return 0, 0
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0
+	}
+	return major, minor
+}
+
+// gitVersionAtLeast reports whether git is at least version major.minor.
+func gitVersionAtLeast(major, minor int) bool {
+	gotMajor, gotMinor := gitVersion()
+	return gotMajor > major || gotMajor == major && gotMinor >= minor
+}
+
+// allPatchesIn reports whether `git cherry base branch` finds an equivalent
+// patch in base for every commit on branch.
+func (m *Manager) allPatchesIn(branch, base string) bool {
+	cmd := exec.Command("git", "cherry", base, branch)
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	lines := strings.Fields(string(out))
+	if len(lines) == 0 {
+		return false
+	}
+	for i := 0; i < len(lines); i += 2 {
+		if lines[i] != "-" {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLeavesTreeUnchanged reports whether merging branch into base would
+// produce exactly base's tree, i.e. base already contains all of branch's changes.
+func (m *Manager) mergeLeavesTreeUnchanged(branch, base string) bool {
+	if !SquashDetectionSupported() {
+		return false
+	}
+	cmd := exec.Command("git", "merge-tree", "--write-tree", base, branch)
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		// Exit status 1 means conflicts; anything else means merge-tree failed
+		return false
+	}
+	mergedTree, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+
+	cmd = exec.Command("git", "rev-parse", base+"^{tree}")
+	cmd.Dir = m.RepoRoot
+	out, err = cmd.Output()
+	if err != nil {
+		return false
+	}
+	return mergedTree != "" && mergedTree == strings.TrimSpace(string(out))
+}
@@ -0,0 +1,154 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGit runs git commands in dir, failing the test on error.
+func runGit(t *testing.T, dir string, cmds ...[]string) {
+	t.Helper()
+	for _, args := range cmds {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+}
+
+// commitFile writes a file in dir and commits it.
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, []string{"add", name}, []string{"commit", "-m", "add " + name})
+}
+
+func TestMergedReason(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	base, err := GetMainBranch(mainRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ff: fast-forward merged into base
+	ffPath := createWorktreeInRepo(t, mainRepo, "ff", "ff")
+	commitFile(t, ffPath, "ff.txt", "ff")
+	runGit(t, mainRepo, []string{"merge", "--ff-only", "ff"})
+
+	// rebased: cherry-picked onto base with a different commit
+	rebasedPath := createWorktreeInRepo(t, mainRepo, "rebased", "rebased")
+	commitFile(t, rebasedPath, "rebased.txt", "rebased")
+	commitFile(t, mainRepo, "base-moved.txt", "moved")
+	runGit(t, mainRepo, []string{"cherry-pick", "rebased"})
+
+	// squashed: two commits squash-merged into one, then base moves on
+	squashedPath := createWorktreeInRepo(t, mainRepo, "squashed", "squashed")
+	commitFile(t, squashedPath, "s1.txt", "one")
+	commitFile(t, squashedPath, "s2.txt", "two")
+	runGit(t, mainRepo,
+		[]string{"merge", "--squash", "squashed"},
+		[]string{"commit", "-m", "squashed"},
+	)
+	commitFile(t, mainRepo, "later.txt", "later")
+
+	// open: has work not in base
+	openPath := createWorktreeInRepo(t, mainRepo, "open", "open")
+	commitFile(t, openPath, "open.txt", "open")
+
+	// fresh: created from base, never committed on
+	createWorktreeInRepo(t, mainRepo, "fresh", "fresh")
+
+	// unlogged: created from base without a reflog, so it's unknown whether it
+	// ever had commits
+	runGit(t, mainRepo, []string{"-c", "core.logAllRefUpdates=false", "branch", "unlogged", base})
+
+	tests := []struct {
+		branch     string
+		wantMerged bool
+		wantReason string
+	}{
+		{"ff", true, "merged into " + base},
+		{"rebased", true, "rebase-merged into " + base},
+		{"squashed", true, "squash-merged into " + base},
+		{"open", false, ""},
+		{"fresh", false, ""},
+		{"unlogged", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			reason, merged := mgr.MergedReason(tt.branch, base)
+			if merged != tt.wantMerged || reason != tt.wantReason {
+				t.Errorf("MergedReason(%s) = %q, %v; want %q, %v", tt.branch, reason, merged, tt.wantReason, tt.wantMerged)
+			}
+		})
+	}
+}
+
+func TestParseGitVersion(t *testing.T) {
+	tests := []struct {
+		out          string
+		major, minor int
+	}{
+		{"git version 2.39.5\n", 2, 39},
+		{"git version 2.37.1 (Apple Git-137.1)\n", 2, 37},
+		{"git version 2.45.windows.1", 2, 45},
+		{"not git", 0, 0},
+	}
+	for _, tt := range tests {
+		major, minor := parseGitVersion(tt.out)
+		if major != tt.major || minor != tt.minor {
+			t.Errorf("parseGitVersion(%q) = %d, %d; want %d, %d", tt.out, major, minor, tt.major, tt.minor)
+		}
+	}
+}
+
+func TestPlanPrune_Merged(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	base, err := GetMainBranch(mainRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Local-only branch that was squash-merged: only found with Merged
+	wtPath := createWorktreeInRepo(t, mainRepo, "done", "done")
+	commitFile(t, wtPath, "done1.txt", "one")
+	commitFile(t, wtPath, "done2.txt", "two")
+	runGit(t, mainRepo,
+		[]string{"merge", "--squash", "done"},
+		[]string{"commit", "-m", "squash done"},
+	)
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	plan, err := mgr.PlanPrune(list, PruneOptions{Jobs: 2})
+	if err != nil {
+		t.Fatalf("PlanPrune failed: %v", err)
+	}
+	if len(plan.Candidates) != 0 {
+		t.Errorf("without Merged, local-only branch should not be a candidate: %+v", plan.Candidates)
+	}
+
+	plan, err = mgr.PlanPrune(list, PruneOptions{Merged: true, BaseBranch: base, Jobs: 2})
+	if err != nil {
+		t.Fatalf("PlanPrune failed: %v", err)
+	}
+	if len(plan.Candidates) != 1 || plan.Candidates[0].Name != "done" {
+		t.Fatalf("expected done as candidate, got %+v", plan.Candidates)
+	}
+	if !strings.HasPrefix(plan.Candidates[0].Reason, "squash-merged") {
+		t.Errorf("reason = %q, want squash-merged", plan.Candidates[0].Reason)
+	}
+}
@@ -0,0 +1,39 @@
+package worktree
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DefaultJobs is the number of concurrent git processes used when no job count is given.
+func DefaultJobs() int {
+	return runtime.NumCPU()
+}
+
+// forEach calls fn for every index in [0, n) using at most jobs goroutines.
+// A jobs value below 1 means DefaultJobs. forEach returns when all calls have finished.
+func forEach(n, jobs int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = DefaultJobs()
+	}
+	if jobs > n {
+		jobs = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,56 @@
+package worktree
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEach_VisitsEveryIndexOnce(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[int]int)
+
+	forEach(50, 4, func(i int) {
+		mu.Lock()
+		seen[i]++
+		mu.Unlock()
+	})
+
+	if len(seen) != 50 {
+		t.Fatalf("visited %d indexes, want 50", len(seen))
+	}
+	for i, n := range seen {
+		if n != 1 {
+			t.Errorf("index %d visited %d times", i, n)
+		}
+	}
+}
+
+func TestForEach_BoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+
+	forEach(20, 3, func(i int) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+	})
+
+	if p := peak.Load(); p > 3 {
+		t.Errorf("peak concurrency = %d, want <= 3", p)
+	}
+}
+
+func TestForEach_Empty(t *testing.T) {
+	called := false
+	forEach(0, 4, func(i int) { called = true })
+	if called {
+		t.Error("fn should not be called for n = 0")
+	}
+}
@@ -0,0 +1,93 @@
+package worktree
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// Ports handed out to worktrees: PortSlots blocks of PortsPerWorktree ports
+// starting at PortRangeStart, below the Linux ephemeral port range.
+const (
+	PortRangeStart   = 10000
+	PortsPerWorktree = 10
+	PortSlots        = 2000
+)
+
+// PortRange is a block of ports reserved for one worktree's dev servers, so
+// servers in different worktrees don't collide.
+type PortRange struct {
+	Base  int `json:"base"`  // First port
+	Count int `json:"count"` // Number of ports
+}
+
+// End returns the last port of the range.
+func (r PortRange) End() int {
+	return r.Base + r.Count - 1
+}
+
+// String renders the range as "first-last".
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Base, r.End())
+}
+
+// Env returns the environment variables that describe the range:
+// WT_PORT_BASE, WT_PORT_END and WT_PORT_COUNT.
+func (r PortRange) Env() map[string]string {
+	return map[string]string{
+		"WT_PORT_BASE":  strconv.Itoa(r.Base),
+		"WT_PORT_END":   strconv.Itoa(r.End()),
+		"WT_PORT_COUNT": strconv.Itoa(r.Count),
+	}
+}
+
+// Ports returns the port range of the worktree called name, as List reports
+// it. Any other directory, such as the main checkout, gets a range no worktree
+// uses.
+func (m *Manager) Ports(name string) (PortRange, error) {
+	worktrees, err := m.List()
+	if err != nil {
+		return PortRange{}, err
+	}
+	taken := make(map[int]bool)
+	for _, wt := range worktrees {
+		if wt.Name == name {
+			return wt.Ports, nil
+		}
+		taken[(wt.Ports.Base-PortRangeStart)/PortsPerWorktree] = true
+	}
+	return m.claimPorts(name, taken), nil
+}
+
+// assignPorts gives each of worktrees, sorted by name, its range. A worktree
+// whose preferred slot an earlier one has taken gets the next free slot, so no
+// two worktrees of the repository share a range; only colliding worktrees can
+// move when others come and go.
+func (m *Manager) assignPorts(worktrees []WorktreeInfo) {
+	taken := make(map[int]bool)
+	for i := range worktrees {
+		worktrees[i].Ports = m.claimPorts(worktrees[i].Name, taken)
+	}
+}
+
+// claimPorts returns the range of the first slot not in taken from the one
+// name prefers on, and adds it to taken.
+func (m *Manager) claimPorts(name string, taken map[int]bool) PortRange {
+	slot := m.portSlot(name)
+	for n := 0; taken[slot] && n < PortSlots; n++ {
+		slot = (slot + 1) % PortSlots
+	}
+	taken[slot] = true
+	return PortRange{Base: PortRangeStart + slot*PortsPerWorktree, Count: PortsPerWorktree}
+}
+
+// portSlot returns the slot the worktree called name prefers. It is derived
+// from the repository root and the name alone, so it stays the same across
+// runs without being recorded anywhere.
+func (m *Manager) portSlot(name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(m.RepoRoot))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	return int(h.Sum32() % PortSlots)
+}
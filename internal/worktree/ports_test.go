@@ -0,0 +1,68 @@
+package worktree
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPortSlot(t *testing.T) {
+	m := NewManager("/repo")
+	slot := m.portSlot("feature-auth")
+
+	if slot != m.portSlot("feature-auth") {
+		t.Errorf("portSlot() is not deterministic")
+	}
+	if slot < 0 || slot >= PortSlots {
+		t.Errorf("slot %d outside the reserved ports", slot)
+	}
+	if m.portSlot("feature-billing") == slot {
+		t.Errorf("different worktrees got the same slot %d", slot)
+	}
+	if NewManager("/other-repo").portSlot("feature-auth") == slot {
+		t.Errorf("worktrees of different repos got the same slot %d", slot)
+	}
+}
+
+func TestAssignPorts(t *testing.T) {
+	m := NewManager("/repo")
+	slot := m.portSlot("feature-auth")
+	// A name that prefers the same slot
+	clash := ""
+	for i := 0; clash == ""; i++ {
+		if name := fmt.Sprintf("feature-%d", i); m.portSlot(name) == slot {
+			clash = name
+		}
+	}
+
+	worktrees := []WorktreeInfo{{Name: clash}, {Name: "feature-auth"}, {Name: "feature-billing"}}
+	m.assignPorts(worktrees)
+
+	base := PortRangeStart + slot*PortsPerWorktree
+	want := []PortRange{
+		{Base: base, Count: PortsPerWorktree},
+		{Base: PortRangeStart + (slot+1)%PortSlots*PortsPerWorktree, Count: PortsPerWorktree},
+		{Base: PortRangeStart + m.portSlot("feature-billing")*PortsPerWorktree, Count: PortsPerWorktree},
+	}
+	for i, wt := range worktrees {
+		if wt.Ports != want[i] {
+			t.Errorf("%s: Ports = %s, want %s", wt.Name, wt.Ports, want[i])
+		}
+	}
+
+	taken := map[int]bool{slot: true, (slot + 1) % PortSlots: true}
+	if got, want := m.claimPorts("feature-auth", taken), (PortRange{Base: PortRangeStart + (slot+2)%PortSlots*PortsPerWorktree, Count: PortsPerWorktree}); got != want {
+		t.Errorf("claimPorts() = %s, want %s", got, want)
+	}
+}
+
+func TestPortRange(t *testing.T) {
+	r := PortRange{Base: 12340, Count: 10}
+	if got := r.String(); got != "12340-12349" {
+		t.Errorf("String() = %q, want 12340-12349", got)
+	}
+	want := map[string]string{"WT_PORT_BASE": "12340", "WT_PORT_END": "12349", "WT_PORT_COUNT": "10"}
+	if got := r.Env(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Env() = %v, want %v", got, want)
+	}
+}
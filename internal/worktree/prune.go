@@ -0,0 +1,113 @@
+package worktree
+
+import (
+	"fmt"
+	"time"
+)
+
+// Prune reasons reported on PruneCandidate.
+const (
+	ReasonRemoteGone = "remote branch deleted"
+)
+
+// PruneCandidate is a worktree proposed for removal by prune.
+type PruneCandidate struct {
+	WorktreeInfo
+	Reason       string    `json:"reason"`                 // Why the worktree is a candidate (e.g., ReasonRemoteGone)
+	LastActivity time.Time `json:"last_activity,omitzero"` // Set when selected by PruneOptions.OlderThan
+}
+
+// PrunePlan lists the worktrees prune would remove.
+type PrunePlan struct {
+	Candidates []PruneCandidate `json:"candidates"`
+}
+
+// PruneOptions controls which worktrees PlanPrune proposes.
+type PruneOptions struct {
+	Merged     bool   // Also propose branches whose changes are already in BaseBranch
+	BaseBranch string // Branch merges are checked against (e.g., "origin/main")
+
+	// OlderThan, if positive, also proposes worktrees with no activity (see
+	// LastActivity) for at least this long, measured from Now (default: time.Now()).
+	OlderThan time.Duration
+	Now       time.Time
+
+	Jobs int // Worktrees inspected in parallel (see forEach)
+}
+
+// PlanPrune selects prune candidates from worktrees. By default these are
+// worktrees whose branch has upstream tracking configured but whose remote
+// branch no longer exists. With opts.Merged, worktrees whose branch is merged
+// into opts.BaseBranch (see MergedReason) are proposed as well, including
+// local-only branches, and with opts.OlderThan so are idle worktrees. Detached
+// worktrees and missing directories are never candidates. Refs are read in one
+// batch, and candidate status is collected in parallel.
+func (m *Manager) PlanPrune(worktrees []WorktreeInfo, opts PruneOptions) (PrunePlan, error) {
+	plan := PrunePlan{Candidates: []PruneCandidate{}}
+
+	refs, err := m.LoadRefs()
+	if err != nil {
+		return plan, err
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	reasons := make([]string, len(worktrees))
+	activity := make([]time.Time, len(worktrees))
+	forEach(len(worktrees), opts.Jobs, func(i int) {
+		wt := worktrees[i]
+		if wt.Branch == "" || wt.Missing {
+			return
+		}
+		if opts.Merged && opts.BaseBranch != "" && !isSameBranch(wt.Branch, opts.BaseBranch) {
+			if reason, ok := m.MergedReason(wt.Branch, opts.BaseBranch); ok {
+				reasons[i] = reason
+				return
+			}
+		}
+		// Local-only branches (no upstream) and branches still on the remote are kept
+		if refs.Upstream(wt.Branch) != "" && !refs.RemoteBranchExists(wt.Branch) {
+			reasons[i] = ReasonRemoteGone
+			return
+		}
+		if opts.OlderThan > 0 {
+			last := m.LastActivity(wt)
+			if idle := now.Sub(last); !last.IsZero() && idle >= opts.OlderThan {
+				reasons[i] = "idle for " + FormatIdle(idle)
+				activity[i] = last
+			}
+		}
+	})
+
+	var infos []WorktreeInfo
+	var picked []int
+	for i, wt := range worktrees {
+		if reasons[i] != "" {
+			infos = append(infos, wt)
+			picked = append(picked, i)
+		}
+	}
+
+	m.CollectStatus(infos, "", opts.Jobs)
+	for j, wt := range infos {
+		i := picked[j]
+		plan.Candidates = append(plan.Candidates, PruneCandidate{WorktreeInfo: wt, Reason: reasons[i], LastActivity: activity[i]})
+	}
+	return plan, nil
+}
+
+// FormatIdle renders an idle duration in whole days, or hours below one day.
+func FormatIdle(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// isSameBranch reports whether branch is base or base's origin counterpart.
+func isSameBranch(branch, base string) bool {
+	return branch == base || "origin/"+branch == base
+}
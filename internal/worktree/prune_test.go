@@ -0,0 +1,81 @@
+package worktree
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestPlanPrune(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	// gone: pushed then deleted on remote; kept: still on remote; local: never pushed
+	for _, branch := range []string{"gone", "kept"} {
+		wtPath := createWorktreeInRepo(t, mainRepo, branch, branch)
+		cmd := exec.Command("git", "push", "-u", "origin", branch)
+		cmd.Dir = wtPath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("push %s failed: %v\n%s", branch, err, out)
+		}
+	}
+	createWorktreeInRepo(t, mainRepo, "local", "local")
+
+	for _, args := range [][]string{
+		{"git", "push", "origin", "--delete", "gone"},
+		{"git", "fetch", "--prune", "origin"},
+	} {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = mainRepo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	plan, err := mgr.PlanPrune(list, PruneOptions{Jobs: 2})
+	if err != nil {
+		t.Fatalf("PlanPrune failed: %v", err)
+	}
+	if len(plan.Candidates) != 1 {
+		t.Fatalf("expected 1 candidate, got %+v", plan.Candidates)
+	}
+	if plan.Candidates[0].Name != "gone" || plan.Candidates[0].Reason != ReasonRemoteGone {
+		t.Errorf("candidate = %+v, want gone with reason %q", plan.Candidates[0], ReasonRemoteGone)
+	}
+	if plan.Candidates[0].Status == nil {
+		t.Error("candidate status should be collected")
+	}
+}
+
+func TestPlanPrune_EmptyIsNotNil(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+	plan, err := mgr.PlanPrune(nil, PruneOptions{Jobs: 1})
+	if err != nil {
+		t.Fatalf("PlanPrune failed: %v", err)
+	}
+	if plan.Candidates == nil {
+		t.Error("Candidates should be an empty slice so JSON renders []")
+	}
+}
+
+func TestFormatIdle(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{5 * time.Hour, "5h"},
+		{36 * time.Hour, "1d"},
+		{16 * 24 * time.Hour, "16d"},
+	}
+	for _, tt := range tests {
+		if got := FormatIdle(tt.d); got != tt.want {
+			t.Errorf("FormatIdle(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
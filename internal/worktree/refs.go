@@ -0,0 +1,62 @@
+package worktree
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Refs is a snapshot of local branches and origin remote-tracking branches,
+// loaded with a single `git for-each-ref` call instead of one git process per branch.
+type Refs struct {
+	upstreams map[string]string // local branch -> upstream short name ("" if none)
+	remotes   map[string]bool   // branch names present under refs/remotes/origin/
+}
+
+// LoadRefs reads all local branches and origin remote-tracking branches.
+func (m *Manager) LoadRefs() (*Refs, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname)%00%(upstream:short)", "refs/heads", "refs/remotes/origin")
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref: %w", err)
+	}
+	return parseRefs(string(out)), nil
+}
+
+// parseRefs parses `git for-each-ref --format=%(refname)%00%(upstream:short)` output.
+func parseRefs(out string) *Refs {
+	refs := &Refs{
+		upstreams: make(map[string]string),
+		remotes:   make(map[string]bool),
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		refname, upstream, _ := strings.Cut(line, "\x00")
+		if branch, ok := strings.CutPrefix(refname, "refs/heads/"); ok {
+			refs.upstreams[branch] = upstream
+		} else if branch, ok := strings.CutPrefix(refname, "refs/remotes/origin/"); ok {
+			refs.remotes[branch] = true
+		}
+	}
+	return refs
+}
+
+// Upstream returns the upstream tracking ref for a local branch (e.g., "origin/main").
+// Returns empty string if the branch has no upstream or does not exist.
+func (r *Refs) Upstream(branch string) string {
+	return r.upstreams[branch]
+}
+
+// BranchExists reports whether a local branch exists.
+func (r *Refs) BranchExists(branch string) bool {
+	_, ok := r.upstreams[branch]
+	return ok
+}
+
+// RemoteBranchExists reports whether a branch exists on the origin remote.
+func (r *Refs) RemoteBranchExists(branch string) bool {
+	return r.remotes[branch]
+}
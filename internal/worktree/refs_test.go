@@ -0,0 +1,62 @@
+package worktree
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestParseRefs(t *testing.T) {
+	out := "refs/heads/main\x00origin/main\n" +
+		"refs/heads/local-only\x00\n" +
+		"refs/remotes/origin/HEAD\x00\n" +
+		"refs/remotes/origin/main\x00\n" +
+		"refs/remotes/origin/feature\x00\n"
+
+	refs := parseRefs(out)
+
+	if got := refs.Upstream("main"); got != "origin/main" {
+		t.Errorf("Upstream(main) = %q, want %q", got, "origin/main")
+	}
+	if got := refs.Upstream("local-only"); got != "" {
+		t.Errorf("Upstream(local-only) = %q, want empty", got)
+	}
+	if !refs.BranchExists("local-only") || refs.BranchExists("feature") {
+		t.Error("BranchExists should only report local branches")
+	}
+	if !refs.RemoteBranchExists("feature") || refs.RemoteBranchExists("local-only") {
+		t.Error("RemoteBranchExists should only report origin branches")
+	}
+}
+
+func TestLoadRefs_MatchesPerBranchLookups(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+
+	cmds := [][]string{
+		{"git", "checkout", "-b", "tracked"},
+		{"git", "commit", "--allow-empty", "-m", "tracked"},
+		{"git", "push", "-u", "origin", "tracked"},
+		{"git", "checkout", "-b", "untracked"},
+	}
+	for _, args := range cmds {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = mainRepo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	mgr := NewManager(mainRepo)
+	refs, err := mgr.LoadRefs()
+	if err != nil {
+		t.Fatalf("LoadRefs failed: %v", err)
+	}
+
+	for _, branch := range []string{"tracked", "untracked", "nonexistent"} {
+		if got, want := refs.Upstream(branch), mgr.BranchUpstream(branch); got != want {
+			t.Errorf("Upstream(%s) = %q, want %q", branch, got, want)
+		}
+		if got, want := refs.RemoteBranchExists(branch), mgr.RemoteBranchExists(branch); got != want {
+			t.Errorf("RemoteBranchExists(%s) = %v, want %v", branch, got, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niref/wt/internal/gitcmd"
+)
+
+// WorktreeStatus summarizes the working tree and branch state of a worktree.
+type WorktreeStatus struct {
+	Uncommitted int       `json:"uncommitted"`          // Untracked, modified and staged paths
+	Upstream    string    `json:"upstream"`             // Upstream tracking ref (e.g., "origin/feature"), empty if none
+	Ahead       int       `json:"ahead"`                // Commits not in the upstream
+	Behind      int       `json:"behind"`               // Upstream commits not in the worktree
+	BaseBranch  string    `json:"base_branch"`          // Branch the base counts are relative to (e.g., "main")
+	BaseAhead   int       `json:"base_ahead"`           // Commits not in the base branch
+	BaseBehind  int       `json:"base_behind"`          // Base branch commits not in the worktree
+	LastCommit  time.Time `json:"last_commit,omitzero"` // Committer date of HEAD, zero if unknown
+}
+
+// Dirty reports whether the worktree has uncommitted changes.
+func (s WorktreeStatus) Dirty() bool {
+	return s.Uncommitted > 0
+}
+
+// AheadBehind counts the commits reachable from rev but not other (ahead)
+// and from other but not rev (behind).
+func (m *Manager) AheadBehind(rev, other string) (ahead, behind int, err error) {
+	cmd := exec.Command("git", "rev-list", "--left-right", "--count", rev+"..."+other)
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("git rev-list %s...%s: %w", rev, other, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q", strings.TrimSpace(string(out)))
+	}
+	if ahead, err = strconv.Atoi(fields[0]); err != nil {
+		return 0, 0, err
+	}
+	if behind, err = strconv.Atoi(fields[1]); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// LastCommitTime returns the committer date of rev.
+func (m *Manager) LastCommitTime(rev string) (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct", rev)
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("git log %s: %w", rev, err)
+	}
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// ResolveCommit returns the commit hash rev points at.
+func (m *Manager) ResolveCommit(rev string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DiffStat summarizes how the working tree at wtPath differs from rev, including
+// commits, uncommitted changes and untracked files that are not ignored, e.g.
+// "3 files changed, 40 insertions(+), 2 deletions(-)". It is empty when nothing changed.
+func (m *Manager) DiffStat(wtPath, rev string) (string, error) {
+	env, cleanup, err := gitcmd.SnapshotIndex(wtPath)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	return gitcmd.Output(wtPath, env, "diff", "--cached", "--shortstat", rev)
+}
+
+// Status collects the status of a worktree. Counts relative to baseBranch
+// are skipped when baseBranch is empty. Comparisons that fail (e.g., an
+// unborn branch) leave the corresponding fields zero.
+func (m *Manager) Status(wt WorktreeInfo, baseBranch string) WorktreeStatus {
+	var upstream string
+	if wt.Branch != "" {
+		upstream = m.BranchUpstream(wt.Branch)
+	}
+	return m.status(wt, baseBranch, upstream)
+}
+
+// status collects the status of a worktree whose upstream is already known.
+func (m *Manager) status(wt WorktreeInfo, baseBranch, upstream string) WorktreeStatus {
+	status := WorktreeStatus{
+		Uncommitted: m.UncommittedCount(wt.Path),
+		Upstream:    upstream,
+		BaseBranch:  baseBranch,
+	}
+
+	rev := wt.Branch
+	if rev == "" {
+		rev = wt.Head
+	}
+	if rev == "" {
+		return status
+	}
+
+	if upstream != "" {
+		status.Ahead, status.Behind, _ = m.AheadBehind(rev, upstream)
+	}
+	if baseBranch != "" && baseBranch != wt.Branch {
+		status.BaseAhead, status.BaseBehind, _ = m.AheadBehind(rev, baseBranch)
+	}
+	status.LastCommit, _ = m.LastCommitTime(rev)
+
+	return status
+}
+
+// CollectStatus fills in Status for every worktree that git knows about and
+// whose directory exists, running up to jobs worktrees concurrently (see forEach).
+// Upstreams are read with a single LoadRefs call. Other worktrees keep a nil Status.
+func (m *Manager) CollectStatus(worktrees []WorktreeInfo, baseBranch string, jobs int) {
+	refs, err := m.LoadRefs()
+	if err != nil {
+		refs = nil
+	}
+
+	forEach(len(worktrees), jobs, func(i int) {
+		wt := &worktrees[i]
+		if wt.Unregistered || wt.Missing {
+			return
+		}
+		var upstream string
+		switch {
+		case wt.Branch == "":
+		case refs != nil:
+			upstream = refs.Upstream(wt.Branch)
+		default:
+			upstream = m.BranchUpstream(wt.Branch)
+		}
+		status := m.status(*wt, baseBranch, upstream)
+		wt.Status = &status
+	})
+}
+
+// LastActivity returns the latest of the worktree's last commit time, the
+// modification times of the files git reports as modified or untracked (not
+// ignored, so build output and dependencies don't count), and the modification
+// times of the worktree's HEAD and index in its git admin dir, which change on
+// checkouts, staging and commits. Returns the zero time if none can be
+// determined.
+func (m *Manager) LastActivity(wt WorktreeInfo) time.Time {
+	var latest time.Time
+	rev := wt.Branch
+	if rev == "" {
+		rev = wt.Head
+	}
+	if rev != "" {
+		latest, _ = m.LastCommitTime(rev)
+	}
+	consider := func(path string) {
+		if info, err := os.Lstat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	cmd := exec.Command("git", "ls-files", "-z", "--modified", "--others", "--exclude-standard")
+	cmd.Dir = wt.Path
+	if out, err := cmd.Output(); err == nil {
+		for _, name := range strings.Split(string(out), "\x00") {
+			if name != "" {
+				consider(filepath.Join(wt.Path, name))
+			}
+		}
+	}
+
+	cmd = exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = wt.Path
+	if out, err := cmd.Output(); err == nil {
+		gitDir := strings.TrimSpace(string(out))
+		consider(filepath.Join(gitDir, "HEAD"))
+		consider(filepath.Join(gitDir, "index"))
+	}
+	return latest
+}
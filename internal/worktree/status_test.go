@@ -0,0 +1,274 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAheadBehind(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+
+	baseBranch, err := GetMainBranch(mainRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := [][]string{
+		{"git", "branch", "side"},
+		{"git", "commit", "--allow-empty", "-m", "base 1"},
+		{"git", "checkout", "side"},
+		{"git", "commit", "--allow-empty", "-m", "side 1"},
+		{"git", "commit", "--allow-empty", "-m", "side 2"},
+	}
+	for _, args := range cmds {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = mainRepo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	mgr := NewManager(mainRepo)
+
+	ahead, behind, err := mgr.AheadBehind("side", baseBranch)
+	if err != nil {
+		t.Fatalf("AheadBehind failed: %v", err)
+	}
+	if ahead != 2 || behind != 1 {
+		t.Errorf("side vs %s = %d/%d, want 2/1", baseBranch, ahead, behind)
+	}
+
+	if _, _, err := mgr.AheadBehind("side", "nonexistent"); err == nil {
+		t.Error("expected error for nonexistent ref")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	baseBranch, err := GetMainBranch(mainRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wtPath := createWorktreeInRepo(t, mainRepo, "status-wt", "status-wt")
+	cmds := [][]string{
+		{"git", "commit", "--allow-empty", "-m", "pushed"},
+		{"git", "push", "-u", "origin", "status-wt"},
+		{"git", "commit", "--allow-empty", "-m", "local 1"},
+		{"git", "commit", "--allow-empty", "-m", "local 2"},
+	}
+	for _, args := range cmds {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = wtPath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	// Advance the base branch so the worktree is also behind it
+	cmd := exec.Command("git", "commit", "--allow-empty", "-m", "base moved")
+	cmd.Dir = mainRepo
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("commit on base failed: %v\n%s", err, out)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(wtPath, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	status := mgr.Status(list[0], baseBranch)
+
+	if status.Uncommitted != 2 || !status.Dirty() {
+		t.Errorf("Uncommitted = %d, want 2", status.Uncommitted)
+	}
+	if status.Upstream != "origin/status-wt" {
+		t.Errorf("Upstream = %q, want %q", status.Upstream, "origin/status-wt")
+	}
+	if status.Ahead != 2 || status.Behind != 0 {
+		t.Errorf("upstream ahead/behind = %d/%d, want 2/0", status.Ahead, status.Behind)
+	}
+	if status.BaseAhead != 3 || status.BaseBehind != 1 {
+		t.Errorf("base ahead/behind = %d/%d, want 3/1", status.BaseAhead, status.BaseBehind)
+	}
+	if status.LastCommit.IsZero() || time.Since(status.LastCommit) > time.Hour {
+		t.Errorf("LastCommit = %v, want a recent time", status.LastCommit)
+	}
+}
+
+func TestCollectStatus_SkipsUnregistered(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	createWorktreeInRepo(t, mainRepo, "real-wt", "real-wt")
+	if err := os.MkdirAll(filepath.Join(mainRepo, ".claude", "worktrees", "stray"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	mgr.CollectStatus(list, "", 2)
+
+	for _, wt := range list {
+		if wt.Unregistered && wt.Status != nil {
+			t.Errorf("%s: unregistered worktree should have nil Status", wt.Name)
+		}
+		if !wt.Unregistered && wt.Status == nil {
+			t.Errorf("%s: registered worktree should have Status", wt.Name)
+		}
+	}
+}
+
+func TestCollectStatus_MatchesSequentialStatus(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	baseBranch, err := GetMainBranch(mainRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("par-%d", i)
+		wtPath := createWorktreeInRepo(t, mainRepo, name, name)
+		for j := 0; j < i; j++ {
+			if err := os.WriteFile(filepath.Join(wtPath, fmt.Sprintf("f%d.txt", j)), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	mgr.CollectStatus(list, baseBranch, 3)
+
+	for _, wt := range list {
+		if wt.Status == nil {
+			t.Fatalf("%s: Status not collected", wt.Name)
+		}
+		want := mgr.Status(wt, baseBranch)
+		if *wt.Status != want {
+			t.Errorf("%s: parallel status %+v, want %+v", wt.Name, *wt.Status, want)
+		}
+	}
+}
+
+func TestLastActivity(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	wtPath := createWorktreeInRepo(t, mainRepo, "activity", "activity")
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	date := old.Format(time.RFC3339)
+	cmd := exec.Command("git", "commit", "--allow-empty", "-m", "old")
+	cmd.Dir = wtPath
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+	// Build output that git ignores is not activity
+	if err := os.WriteFile(filepath.Join(wtPath, ".gitignore"), []byte("build/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, wtPath, []string{"add", ".gitignore"})
+	cmd = exec.Command("git", "commit", "-m", "ignore build")
+	cmd.Dir = wtPath
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("commit failed: %v\n%s", err, out)
+	}
+	if err := os.MkdirAll(filepath.Join(wtPath, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wtPath, "build", "out.bin"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The commits touched HEAD and the index in the worktree's admin dir
+	adminDir := filepath.Join(mainRepo, ".git", "worktrees", filepath.Base(wtPath))
+	for _, path := range []string{wtPath, filepath.Join(wtPath, ".gitignore"), filepath.Join(adminDir, "HEAD"), filepath.Join(adminDir, "index")} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wt := WorktreeInfo{Name: "activity", Branch: "activity", Path: wtPath}
+	if last := mgr.LastActivity(wt); time.Since(last) < 29*24*time.Hour {
+		t.Errorf("LastActivity = %v, want about 30 days ago", last)
+	}
+
+	// Touching a file in the working tree counts as activity
+	if err := os.WriteFile(filepath.Join(wtPath, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if last := mgr.LastActivity(wt); time.Since(last) > time.Hour {
+		t.Errorf("LastActivity = %v, want recent after writing a file", last)
+	}
+}
+
+func TestDiffStat(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	base, err := mgr.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("ResolveCommit failed: %v", err)
+	}
+	if err := mgr.CreateNew("try", base); err != nil {
+		t.Fatalf("CreateNew failed: %v", err)
+	}
+	wtPath := mgr.WorktreePath("try")
+
+	if stat, err := mgr.DiffStat(wtPath, base); err != nil || stat != "" {
+		t.Errorf("DiffStat of fresh worktree = %q, %v; want empty", stat, err)
+	}
+
+	commitFile(t, wtPath, "a.txt", "one\ntwo\n")
+	if err := os.WriteFile(filepath.Join(wtPath, "a.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := mgr.DiffStat(wtPath, base)
+	if err != nil {
+		t.Fatalf("DiffStat failed: %v", err)
+	}
+	if want := "1 file changed, 1 insertion(+)"; stat != want {
+		t.Errorf("DiffStat = %q, want %q", stat, want)
+	}
+
+	// Untracked files count, without being added to the worktree's index
+	if err := os.WriteFile(filepath.Join(wtPath, "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err = mgr.DiffStat(wtPath, base)
+	if err != nil {
+		t.Fatalf("DiffStat failed: %v", err)
+	}
+	if want := "2 files changed, 2 insertions(+)"; stat != want {
+		t.Errorf("DiffStat with an untracked file = %q, want %q", stat, want)
+	}
+	cmd := exec.Command("git", "status", "--porcelain", "new.txt")
+	cmd.Dir = wtPath
+	if out, err := cmd.Output(); err != nil || !strings.HasPrefix(string(out), "??") {
+		t.Errorf("new.txt status = %q, %v; want it still untracked", out, err)
+	}
+
+	if _, err := mgr.ResolveCommit("nonexistent"); err == nil {
+		t.Error("expected error for nonexistent ref")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -68,31 +70,60 @@ func (m *Manager) BranchUpstream(branch string) string {
 // HasUncommittedChanges checks if a worktree has uncommitted changes.
 // This includes untracked files, modified files, and staged changes.
 func (m *Manager) HasUncommittedChanges(wtPath string) bool {
+	return m.UncommittedCount(wtPath) > 0
+}
+
+// UncommittedCount returns the number of untracked, modified and staged paths in a worktree.
+// Returns 0 if the status cannot be read.
+func (m *Manager) UncommittedCount(wtPath string) int {
 	cmd := exec.Command("git", "status", "--porcelain")
 	cmd.Dir = wtPath
 	out, err := cmd.Output()
 	if err != nil {
-		return false
+		return 0
+	}
+	trimmed := strings.TrimSpace(string(out))
+	if trimmed == "" {
+		return 0
 	}
-	return len(strings.TrimSpace(string(out))) > 0
+	return len(strings.Split(trimmed, "\n"))
 }
 
 // HasUnpushedCommits checks if a branch has commits not pushed to its upstream.
 // Returns false if the branch has no upstream configured.
 func (m *Manager) HasUnpushedCommits(branch string) bool {
+	return m.UnpushedCount(branch) > 0
+}
+
+// UnpushedCount returns the number of commits in branch that are not in its upstream.
+// Returns 0 if the branch has no upstream configured.
+func (m *Manager) UnpushedCount(branch string) int {
 	upstream := m.BranchUpstream(branch)
 	if upstream == "" {
-		return false
+		return 0
 	}
-	// Count commits in branch that are not in upstream
-	cmd := exec.Command("git", "rev-list", "--count", upstream+".."+branch)
+	ahead, _, err := m.AheadBehind(branch, upstream)
+	if err != nil {
+		return 0
+	}
+	return ahead
+}
+
+// UnpublishedCount returns the number of commits in branch that are not on any
+// remote-tracking branch, i.e. commits that would be lost with the local branch.
+// Returns 0 if the count cannot be determined.
+func (m *Manager) UnpublishedCount(branch string) int {
+	cmd := exec.Command("git", "rev-list", "--count", branch, "--not", "--remotes")
 	cmd.Dir = m.RepoRoot
 	out, err := cmd.Output()
 	if err != nil {
-		return false
+		return 0
 	}
-	count := strings.TrimSpace(string(out))
-	return count != "0"
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0
+	}
+	return n
 }
 
 // DeleteBranch deletes a local branch.
@@ -113,41 +144,150 @@ func (m *Manager) DeleteBranch(branch string, force bool) error {
 
 // WorktreeInfo holds information about a worktree.
 type WorktreeInfo struct {
-	Name   string // Directory name (e.g., "feature-auth")
-	Branch string // Git branch (e.g., "worktree-feature-auth")
-	Path   string // Full filesystem path
+	Name           string `json:"name"`                      // Directory name (e.g., "feature-auth")
+	Branch         string `json:"branch"`                    // Git branch (e.g., "worktree-feature-auth"), empty when detached
+	Path           string `json:"path"`                      // Full filesystem path
+	Head           string `json:"head"`                      // Commit SHA checked out in the worktree
+	Detached       bool   `json:"detached"`                  // HEAD is detached
+	Bare           bool   `json:"bare"`                      // Git reports the worktree as bare
+	Locked         bool   `json:"locked"`                    // Worktree is locked with `git worktree lock`
+	LockReason     string `json:"lock_reason,omitempty"`     // Reason given when locking, if any
+	Prunable       bool   `json:"prunable"`                  // Git would remove the record on `git worktree prune`
+	PrunableReason string `json:"prunable_reason,omitempty"` // Why git considers the worktree prunable
+	Unregistered   bool   `json:"unregistered"`              // Directory exists but git has no worktree record for it
+	Missing        bool   `json:"missing"`                   // Git has a worktree record but the directory is gone
+
+	Ports PortRange `json:"ports"` // Ports reserved for the worktree's dev servers, see Manager.assignPorts
+
+	Status *WorktreeStatus `json:"status,omitempty"` // Filled in by CollectStatus, nil otherwise
 }
 
 // List returns all worktrees in .claude/worktrees/ for this repo.
+// Worktrees known to git are reported with their git state; directories
+// git doesn't know about are reported with Unregistered set.
 func (m *Manager) List() ([]WorktreeInfo, error) {
-	wtDir := filepath.Join(m.RepoRoot, ".claude", "worktrees")
-
-	entries, err := os.ReadDir(wtDir)
-	if os.IsNotExist(err) {
-		return nil, nil
-	}
+	records, err := m.listGitWorktrees()
 	if err != nil {
 		return nil, err
 	}
 
+	wtDir := filepath.Join(m.RepoRoot, ".claude", "worktrees")
+	dirs := worktreeDirCandidates(m.RepoRoot)
+
 	var worktrees []WorktreeInfo
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		name, ok := worktreeNameFor(dirs, rec.Path)
+		if !ok {
+			continue
+		}
+		wt := rec
+		wt.Name = name
+		wt.Path = m.WorktreePath(name)
+		if _, err := os.Stat(wt.Path); os.IsNotExist(err) {
+			wt.Missing = true
+		}
+		seen[name] = true
+		worktrees = append(worktrees, wt)
+	}
+
+	entries, err := os.ReadDir(wtDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() || seen[entry.Name()] {
 			continue
 		}
-		name := entry.Name()
-		wtPath := filepath.Join(wtDir, name)
-		branch := branchForWorktree(wtPath)
 		worktrees = append(worktrees, WorktreeInfo{
-			Name:   name,
-			Branch: branch,
-			Path:   wtPath,
+			Name:         entry.Name(),
+			Path:         filepath.Join(wtDir, entry.Name()),
+			Unregistered: true,
 		})
 	}
 
+	sort.Slice(worktrees, func(i, j int) bool {
+		return worktrees[i].Name < worktrees[j].Name
+	})
+	m.assignPorts(worktrees)
 	return worktrees, nil
 }
 
+// listGitWorktrees runs `git worktree list --porcelain -z` in the repo root.
+func (m *Manager) listGitWorktrees() ([]WorktreeInfo, error) {
+	cmd := exec.Command("git", "worktree", "list", "--porcelain", "-z")
+	cmd.Dir = m.RepoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git worktree list: %w", err)
+	}
+	return parseWorktreeList(out), nil
+}
+
+// parseWorktreeList parses NUL-terminated `git worktree list --porcelain -z` output.
+// Each record starts with a "worktree <path>" attribute and ends with an empty field.
+// Name is left empty; Path is the path as reported by git.
+func parseWorktreeList(data []byte) []WorktreeInfo {
+	var worktrees []WorktreeInfo
+	var cur *WorktreeInfo
+	for _, field := range strings.Split(string(data), "\x00") {
+		if field == "" {
+			cur = nil
+			continue
+		}
+		key, value, _ := strings.Cut(field, " ")
+		if key == "worktree" {
+			worktrees = append(worktrees, WorktreeInfo{Path: value})
+			cur = &worktrees[len(worktrees)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		switch key {
+		case "HEAD":
+			cur.Head = value
+		case "branch":
+			cur.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "detached":
+			cur.Detached = true
+		case "bare":
+			cur.Bare = true
+		case "locked":
+			cur.Locked = true
+			cur.LockReason = value
+		case "prunable":
+			cur.Prunable = true
+			cur.PrunableReason = value
+		}
+	}
+	return worktrees
+}
+
+// worktreeDirCandidates returns the spellings of .claude/worktrees/ that git may
+// report: the path as given and, if different, with symlinks resolved.
+func worktreeDirCandidates(repoRoot string) []string {
+	dir := filepath.Join(repoRoot, ".claude", "worktrees")
+	dirs := []string{dir}
+	if resolved, err := filepath.EvalSymlinks(repoRoot); err == nil {
+		if r := filepath.Join(resolved, ".claude", "worktrees"); r != dir {
+			dirs = append(dirs, r)
+		}
+	}
+	return dirs
+}
+
+// worktreeNameFor returns the worktree name for a path that is a direct child
+// of one of dirs.
+func worktreeNameFor(dirs []string, path string) (string, bool) {
+	for _, dir := range dirs {
+		if filepath.Dir(filepath.Clean(path)) == dir {
+			return filepath.Base(path), true
+		}
+	}
+	return "", false
+}
+
 // branchForWorktree reads the branch checked out in a worktree.
 func branchForWorktree(wtPath string) string {
 	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
@@ -161,8 +301,21 @@ func branchForWorktree(wtPath string) string {
 
 // Remove removes a worktree by name.
 // If force is true, removes even if worktree has uncommitted changes.
-// Also deletes the associated local branch.
+// Also deletes the associated local branch after archiving the worktree.
 func (m *Manager) Remove(name string, force bool) error {
+	return m.RemoveWithOptions(name, RemoveOptions{Force: force})
+}
+
+// RemoveOptions controls how RemoveWithOptions removes a worktree.
+type RemoveOptions struct {
+	Force      bool // Remove even if the worktree has uncommitted changes
+	KeepBranch bool // Keep the local branch instead of force-deleting it
+}
+
+// RemoveWithOptions removes a worktree by name and, unless opts.KeepBranch is set,
+// force-deletes its local branch. The worktree is archived first (see Archive) so
+// it can be brought back with Restore; removal is aborted if archiving fails.
+func (m *Manager) RemoveWithOptions(name string, opts RemoveOptions) error {
 	wtPath := m.WorktreePath(name)
 
 	if !m.Exists(name) {
@@ -172,8 +325,12 @@ func (m *Manager) Remove(name string, force bool) error {
 	// Read the branch name before removing the worktree
 	branch := branchForWorktree(wtPath)
 
+	if _, err := m.Archive(name); err != nil {
+		return fmt.Errorf("archiving worktree %q: %w", name, err)
+	}
+
 	args := []string{"worktree", "remove"}
-	if force {
+	if opts.Force {
 		args = append(args, "--force")
 	}
 	args = append(args, wtPath)
@@ -185,7 +342,7 @@ func (m *Manager) Remove(name string, force bool) error {
 	}
 
 	// Delete the local branch (force because remote may be gone)
-	if branch != "" {
+	if branch != "" && branch != "HEAD" && !opts.KeepBranch {
 		_ = m.DeleteBranch(branch, true)
 	}
 
@@ -205,8 +362,40 @@ func (m *Manager) FetchPrune() error {
 // Create creates a new worktree at .claude/worktrees/<name>/ from a remote branch.
 // The local branch is created with the given name, tracking the remote branch.
 func (m *Manager) Create(name, remoteBranch string) error {
+	return m.addWorktree(name, name, remoteBranch)
+}
+
+// BranchPrefix is prepended to the worktree name to form its branch name,
+// matching the branches `claude --worktree <name>` creates.
+const BranchPrefix = "worktree-"
+
+// BranchName returns the branch name Claude Code uses for a worktree.
+func BranchName(name string) string {
+	return BranchPrefix + name
+}
+
+// CreateNew creates a new worktree at .claude/worktrees/<name>/ on a new branch
+// worktree-<name> starting at base, the same layout `claude --worktree <name>` produces.
+// An empty base starts from HEAD of the main checkout. The branch does not track base.
+func (m *Manager) CreateNew(name, base string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid worktree name %q", name)
+	}
+	if m.Exists(name) {
+		return fmt.Errorf("worktree %q already exists", name)
+	}
+	if base == "" {
+		base = "HEAD"
+	}
+	return m.addWorktree(name, BranchName(name), base, "--no-track")
+}
+
+// addWorktree runs `git worktree add -b <branch>` for .claude/worktrees/<name>/.
+func (m *Manager) addWorktree(name, branch, startPoint string, extraArgs ...string) error {
 	wtPath := m.WorktreePath(name)
-	cmd := exec.Command("git", "worktree", "add", "-b", name, wtPath, remoteBranch)
+	args := append([]string{"worktree", "add"}, extraArgs...)
+	args = append(args, "-b", branch, wtPath, startPoint)
+	cmd := exec.Command("git", args...)
 	cmd.Dir = m.RepoRoot
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -883,3 +883,231 @@ func TestCopyWorktreeInclude_MissingSource(t *testing.T) {
 		t.Fatalf("CopyWorktreeInclude() should skip missing files silently, got: %v", err)
 	}
 }
+
+func TestParseWorktreeList(t *testing.T) {
+	data := "worktree /repo\x00HEAD aaaa\x00branch refs/heads/main\x00\x00" +
+		"worktree /repo/.claude/worktrees/locked\x00HEAD bbbb\x00branch refs/heads/wt-locked\x00locked on usb\x00\x00" +
+		"worktree /repo/.claude/worktrees/detached\x00HEAD cccc\x00detached\x00\x00" +
+		"worktree /repo/.claude/worktrees/gone\x00HEAD dddd\x00branch refs/heads/gone\x00prunable gitdir file points to non-existent location\x00\x00"
+
+	got := parseWorktreeList([]byte(data))
+	want := []WorktreeInfo{
+		{Path: "/repo", Head: "aaaa", Branch: "main"},
+		{Path: "/repo/.claude/worktrees/locked", Head: "bbbb", Branch: "wt-locked", Locked: true, LockReason: "on usb"},
+		{Path: "/repo/.claude/worktrees/detached", Head: "cccc", Detached: true},
+		{Path: "/repo/.claude/worktrees/gone", Head: "dddd", Branch: "gone", Prunable: true, PrunableReason: "gitdir file points to non-existent location"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestList_GitState(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	lockedPath := createWorktreeInRepo(t, mainRepo, "locked", "locked")
+	detachedPath := filepath.Join(mainRepo, ".claude", "worktrees", "detached")
+	for _, args := range [][]string{
+		{"git", "worktree", "lock", "--reason", "in use", lockedPath},
+		{"git", "worktree", "add", "--detach", detachedPath},
+	} {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = mainRepo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 worktrees, got %d", len(list))
+	}
+
+	// Sorted by name: detached, locked
+	detached, locked := list[0], list[1]
+	if !detached.Detached || detached.Branch != "" {
+		t.Errorf("detached worktree = %+v, want Detached with no branch", detached)
+	}
+	if len(detached.Head) != 40 {
+		t.Errorf("detached Head = %q, want full SHA", detached.Head)
+	}
+	if !locked.Locked || locked.LockReason != "in use" {
+		t.Errorf("locked worktree = %+v, want Locked with reason %q", locked, "in use")
+	}
+}
+
+func TestList_UnregisteredDirectory(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	createWorktreeInRepo(t, mainRepo, "real-wt", "real-wt")
+	strayDir := filepath.Join(mainRepo, ".claude", "worktrees", "stray")
+	if err := os.MkdirAll(strayDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 worktrees, got %d", len(list))
+	}
+	if list[0].Name != "real-wt" || list[0].Unregistered {
+		t.Errorf("real-wt = %+v, want registered worktree", list[0])
+	}
+	if list[1].Name != "stray" || !list[1].Unregistered {
+		t.Errorf("stray = %+v, want Unregistered", list[1])
+	}
+}
+
+func TestList_MissingDirectory(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	wtPath := createWorktreeInRepo(t, mainRepo, "vanished", "vanished")
+	if err := os.RemoveAll(wtPath); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := mgr.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 worktree, got %d", len(list))
+	}
+	if !list[0].Missing || !list[0].Prunable {
+		t.Errorf("vanished = %+v, want Missing and Prunable", list[0])
+	}
+	if list[0].Branch != "vanished" {
+		t.Errorf("Branch = %q, want %q", list[0].Branch, "vanished")
+	}
+}
+
+func TestCreateNew(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	if err := mgr.CreateNew("feature-x", ""); err != nil {
+		t.Fatalf("CreateNew() error: %v", err)
+	}
+
+	wtPath := mgr.WorktreePath("feature-x")
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	cmd.Dir = wtPath
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("rev-parse: %v\n%s", err, out)
+	}
+	if branch := strings.TrimSpace(string(out)); branch != "worktree-feature-x" {
+		t.Errorf("expected branch worktree-feature-x, got %q", branch)
+	}
+
+	// Starting from the main checkout's HEAD must not set up tracking
+	if upstream := mgr.BranchUpstream("worktree-feature-x"); upstream != "" {
+		t.Errorf("new branch should not track anything, got %q", upstream)
+	}
+}
+
+func TestCreateNew_FromBase(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+
+	for _, args := range [][]string{
+		{"git", "checkout", "-b", "base-branch"},
+		{"git", "commit", "--allow-empty", "-m", "base commit"},
+		{"git", "push", "-u", "origin", "base-branch"},
+		{"git", "checkout", "main"},
+	} {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = mainRepo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	mgr := NewManager(mainRepo)
+	if err := mgr.CreateNew("from-base", "origin/base-branch"); err != nil {
+		t.Fatalf("CreateNew() error: %v", err)
+	}
+
+	ahead, behind, err := mgr.AheadBehind("worktree-from-base", "origin/base-branch")
+	if err != nil {
+		t.Fatalf("AheadBehind: %v", err)
+	}
+	if ahead != 0 || behind != 0 {
+		t.Errorf("worktree branch should start at base, got ahead %d behind %d", ahead, behind)
+	}
+	if upstream := mgr.BranchUpstream("worktree-from-base"); upstream != "" {
+		t.Errorf("new branch should not track its base, got %q", upstream)
+	}
+}
+
+func TestCreateNew_Errors(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	for _, name := range []string{"", "a/b", ".hidden"} {
+		if err := mgr.CreateNew(name, ""); err == nil {
+			t.Errorf("CreateNew(%q) should fail for invalid name", name)
+		}
+	}
+
+	if err := mgr.CreateNew("twice", ""); err != nil {
+		t.Fatalf("CreateNew() error: %v", err)
+	}
+	if err := mgr.CreateNew("twice", ""); err == nil {
+		t.Error("CreateNew should fail when the worktree already exists")
+	}
+	if err := mgr.CreateNew("bad-base", "no-such-ref"); err == nil {
+		t.Error("CreateNew should fail for an unknown base")
+	}
+}
+
+func TestRemoveWithOptions_KeepBranch(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	wtPath := createWorktreeInRepo(t, mainRepo, "keep", "keep-branch")
+
+	if err := mgr.RemoveWithOptions("keep", RemoveOptions{KeepBranch: true}); err != nil {
+		t.Fatalf("RemoveWithOptions failed: %v", err)
+	}
+	if _, err := os.Stat(wtPath); !os.IsNotExist(err) {
+		t.Error("worktree directory should be removed")
+	}
+	if !mgr.BranchExists("keep-branch") {
+		t.Error("branch should be kept with KeepBranch")
+	}
+}
+
+func TestUnpublishedCount(t *testing.T) {
+	mainRepo, _ := setupRepoWithRemote(t)
+	mgr := NewManager(mainRepo)
+
+	wtPath := createWorktreeInRepo(t, mainRepo, "unpublished", "unpublished")
+	if n := mgr.UnpublishedCount("unpublished"); n != 0 {
+		t.Errorf("fresh branch UnpublishedCount = %d, want 0", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		cmd := exec.Command("git", "commit", "--allow-empty", "-m", "local")
+		cmd.Dir = wtPath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("commit failed: %v\n%s", err, out)
+		}
+	}
+	if n := mgr.UnpublishedCount("unpublished"); n != 2 {
+		t.Errorf("UnpublishedCount = %d, want 2", n)
+	}
+}